@@ -17,27 +17,68 @@ Copy files from source to destination:
 
   filer cp -r tmp gs://bucket
   filer cp -r gs://bucket tmp
+  filer cp -r --resume tmp gs://bucket
+  filer cp -r --dry-run --json tmp gs://bucket
+  filer cp 'gs://bucket/raw/*.jpg' out
+  filer cp -r --exclude '*.tmp' --filebignore tmp gs://bucket
+  filer cp -r --bwlimit "08:00,5M 20:00,off" tmp gs://bucket
 `,
 	Args: cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		verbose, _ := cmd.Flags().GetBool("verbose")
 		recursive, _ := cmd.Flags().GetBool("recursive")
 		logger := NewLogger(verbose)
-		source, dest := validateArgs(args)
+		source, dest, err := validateArgs(args)
+		if err != nil {
+			return err
+		}
 		srcURI, err := filesys.ParseURI(source)
-		fatalIfError(err)
+		if err != nil {
+			return err
+		}
 		logger.Debugf("Source URI: %s", srcURI)
 
 		dstURI, err := filesys.ParseURI(dest)
-		fatalIfError(err)
+		if err != nil {
+			return err
+		}
 		logger.Debugf("Destination URI: %s", dstURI)
 
-		err = filesys.Copy(srcURI, dstURI, recursive)
-		fatalIfError(err)
+		filter, err := filterOptions(cmd)
+		if err != nil {
+			return err
+		}
+		client, err := newClient(cmd)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		srcs, err := expandURI(cmd, client, srcURI, logger)
+		if err != nil {
+			return err
+		}
+		var opts []filesys.CopyOption
+		done := func(error) {}
+		if !dryRun(cmd) {
+			if opts, done, err = transferOptions(cmd, srcURI, dstURI, logger); err != nil {
+				return err
+			}
+		}
+		err = transferMatches(cmd, client, srcs, dstURI, logger,
+			func(src, dst filesys.URI) (filesys.Plan, error) {
+				return client.PlanCopy(cmd.Context(), src, dst, recursive, filesys.WithFilter(filter))
+			},
+			func(src, dst filesys.URI) error {
+				return client.Copy(cmd.Context(), src, dst, recursive, opts...)
+			})
+		done(err)
+		return err
 	},
 }
 
 func init() {
 	cpCMD.Flags().BoolP("recursive", "r", false, "Copy directories recursively")
+	addTransferFlags(cpCMD)
+	addPlanFlags(cpCMD)
 	RootCmd.AddCommand(cpCMD)
 }
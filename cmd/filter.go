@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/B87/file-bridge/pkg/filesys"
+)
+
+// addFilterFlags adds the flags selecting the files of recursive commands
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("include", nil, "Only handle the files matching the pattern, can be repeated")
+	cmd.Flags().StringArray("exclude", nil, "Skip the files and directories matching the pattern, can be repeated")
+	cmd.Flags().StringArray("exclude-from", nil, "Read exclude patterns from a local file, one per line")
+	cmd.Flags().Bool("filebignore", false, "Skip the paths excluded by the "+filesys.IgnoreFileName+" file of every directory")
+	cmd.Flags().String("min-size", "", "Skip the files smaller than this size, eg. 10K or 5M")
+	cmd.Flags().String("max-age", "", "Skip the files modified longer ago than this, eg. 36h or 7d")
+}
+
+// filterOptions returns the filter set by the filter flags, nil if none is set
+func filterOptions(cmd *cobra.Command) (*filesys.Filter, error) {
+	var f filesys.Filter
+	f.Include, _ = cmd.Flags().GetStringArray("include")
+	f.Exclude, _ = cmd.Flags().GetStringArray("exclude")
+	excludeFrom, _ := cmd.Flags().GetStringArray("exclude-from")
+	for _, name := range excludeFrom {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		patterns, err := filesys.ReadPatterns(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		f.Exclude = append(f.Exclude, patterns...)
+	}
+	if ignore, _ := cmd.Flags().GetBool("filebignore"); ignore {
+		f.IgnoreFile = filesys.IgnoreFileName
+	}
+	if minSize, _ := cmd.Flags().GetString("min-size"); minSize != "" {
+		var err error
+		if f.MinSize, err = filesys.ParseSize(minSize); err != nil {
+			return nil, err
+		}
+	}
+	if maxAge, _ := cmd.Flags().GetString("max-age"); maxAge != "" {
+		var err error
+		if f.MaxAge, err = parseAge(maxAge); err != nil {
+			return nil, err
+		}
+	}
+	if len(f.Include) == 0 && len(f.Exclude) == 0 && f.IgnoreFile == "" && f.MinSize == 0 && f.MaxAge == 0 {
+		return nil, nil
+	}
+	return &f, nil
+}
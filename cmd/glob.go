@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/B87/file-bridge/pkg/filesys"
+)
+
+/*
+expandURI returns the uris matching the glob pattern of uri,
+or uri itself when it has no pattern.
+
+returns a *PathError wrapping ErrNotFound if the pattern has no match
+*/
+func expandURI(cmd *cobra.Command, client *filesys.Client, uri filesys.URI, logger *Logger) ([]filesys.URI, error) {
+	if !filesys.HasMeta(uri.Path) {
+		return []filesys.URI{uri}, nil
+	}
+	nodes, err := client.Glob(cmd.Context(), uri)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, &filesys.PathError{Op: "glob", URI: uri, Err: filesys.ErrNotFound}
+	}
+	uris := make([]filesys.URI, len(nodes))
+	for i, node := range nodes {
+		uris[i] = node.URI
+		logger.Debugf("Matched: %s", node.URI)
+	}
+	return uris, nil
+}
+
+/*
+transferMatches copies or moves every source to dst with fn,
+or adds their plan with planFn on --dry-run.
+
+Several sources are copied into the directory dst, like cp a b dir:
+dst is created if missing. Every source is attempted, the errors are joined.
+*/
+func transferMatches(cmd *cobra.Command, client *filesys.Client, srcs []filesys.URI, dst filesys.URI, logger *Logger,
+	planFn func(src, dst filesys.URI) (filesys.Plan, error), fn func(src, dst filesys.URI) error) error {
+	ctx := cmd.Context()
+	var plan filesys.Plan
+	intoDir := false
+	if len(srcs) > 1 {
+		node, err := client.Get(ctx, dst)
+		switch {
+		case err == nil && !node.IsDir:
+			return &filesys.PathError{Op: "copy", URI: dst, Err: filesys.ErrNotDir}
+		case errors.Is(err, filesys.ErrNotFound) && dryRun(cmd):
+			plan, err = client.PlanMkDir(ctx, dst)
+			if err != nil {
+				return err
+			}
+			intoDir = true
+		case errors.Is(err, filesys.ErrNotFound):
+			if _, err := client.MkDir(ctx, dst); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		}
+	}
+
+	var errs []error
+	for _, src := range srcs {
+		target := dst
+		// Planned into a directory which does not exist yet
+		if intoDir {
+			target = dst.Join(src.Name)
+		}
+		if dryRun(cmd) {
+			p, err := planFn(src, target)
+			if err != nil {
+				return err
+			}
+			plan.Merge(p)
+			continue
+		}
+		if err := fn(src, target); err != nil {
+			logger.Debugf("%s: %v", src, err)
+			errs = append(errs, err)
+		}
+	}
+	if dryRun(cmd) {
+		return printPlan(cmd, plan, logger)
+	}
+	return errors.Join(errs...)
+}
@@ -9,9 +9,14 @@ import (
 var ListCmd = &cobra.Command{
 	Use:   "ls [directory]",
 	Short: "List files in a directory",
-	Long:  `List files in a directory`,
-	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `
+List files in a directory, or the files matching a glob pattern:
+
+  filer ls -r tmp
+  filer ls 'gs://bucket/raw/*.jpg'
+`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		var dir string
 		if len(args) == 0 {
 			dir = "."
@@ -25,19 +30,43 @@ var ListCmd = &cobra.Command{
 		logger.PrintDebugGlobal()
 
 		uri, err := filesys.ParseURI(dir)
-		fatalIfError(err)
-		files, err := filesys.List(uri, recursive)
-		fatalIfError(err)
+		if err != nil {
+			return err
+		}
+		filter, err := filterOptions(cmd)
+		if err != nil {
+			return err
+		}
 
-		logger.Debug("Listing files in", uri.Path, "from file system", uri.Scheme, "...\n")
-		for _, file := range files {
-			logger.Print(file.URI.Path)
+		client, err := newClient(cmd)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		if filesys.HasMeta(uri.Path) {
+			matches, err := expandURI(cmd, client, uri, logger)
+			for _, match := range matches {
+				logger.Print(match.Path)
+			}
+			return err
 		}
 
+		logger.Debug("Listing files in", uri.Path, "from file system", uri.Scheme, "...\n")
+		opts := filesys.WalkOptions{Recursive: recursive, Filter: filter}
+		return client.Walk(cmd.Context(), uri, opts, func(node filesys.Node) error {
+			if node.IsLink {
+				logger.Print(node.URI.Path, "->", node.LinkTarget)
+				return nil
+			}
+			logger.Print(node.URI.Path)
+			return nil
+		})
 	},
 }
 
 func init() {
 	ListCmd.Flags().BoolP("recursive", "r", false, "List files recursively")
+	addFilterFlags(ListCmd)
+	addSymlinkFlag(ListCmd)
 	RootCmd.AddCommand(ListCmd)
 }
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/B87/file-bridge/pkg/filesys"
+)
+
+var metaCmd = &cobra.Command{
+	Use:   "meta",
+	Short: "Get or set the metadata of files",
+	Long: `
+Get or set the content type, cache control, content encoding, storage class
+and custom metadata of files. Local files keep the content type and the
+custom metadata in extended attributes, on Linux only:
+
+  filer meta get gs://bucket/index.html
+  filer meta set --cache-control "public, max-age=3600" 'gs://bucket/**/*.css'
+  filer meta set --metadata owner=data,source=camera tmp/photo.jpg
+`,
+}
+
+var metaGetCmd = &cobra.Command{
+	Use:   "get [file]",
+	Short: "Print the metadata of a file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		logger := NewLogger(verbose)
+		uri, err := filesys.ParseURI(args[0])
+		if err != nil {
+			return err
+		}
+		client, err := newClient(cmd)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		md, err := client.GetMetadata(cmd.Context(), uri)
+		if err != nil {
+			return err
+		}
+		for _, field := range []struct{ name, value string }{
+			{"Content-Type", md.ContentType},
+			{"Cache-Control", md.CacheControl},
+			{"Content-Encoding", md.ContentEncoding},
+			{"Storage-Class", md.StorageClass},
+		} {
+			if field.value != "" {
+				logger.Printf("%s: %s", field.name, field.value)
+			}
+		}
+		if len(md.Custom) == 0 {
+			return nil
+		}
+		logger.Print("Metadata:")
+		keys := make([]string, 0, len(md.Custom))
+		for key := range md.Custom {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			logger.Printf("  %s: %s", key, md.Custom[key])
+		}
+		return nil
+	},
+}
+
+var metaSetCmd = &cobra.Command{
+	Use:   "set [file]",
+	Short: "Set the metadata of a file, or of every file matching a glob pattern",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		logger := NewLogger(verbose)
+		uri, err := filesys.ParseURI(args[0])
+		if err != nil {
+			return err
+		}
+		md := metadataFlags(cmd)
+		if md.IsZero() {
+			return errors.New("no metadata to set, see fileb meta set -h")
+		}
+		client, err := newClient(cmd)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		uris, err := expandURI(cmd, client, uri, logger)
+		if err != nil {
+			return err
+		}
+		var errs []error
+		for _, uri := range uris {
+			logger.Debug("Setting metadata of", uri.String(), "...")
+			if err := client.SetMetadata(cmd.Context(), uri, md); err != nil {
+				logger.Debugf("%s: %v", uri, err)
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	},
+}
+
+// addMetadataFlags adds the flags setting the metadata of the written files
+func addMetadataFlags(cmd *cobra.Command) {
+	cmd.Flags().String("content-type", "", "Content type of the files, detected from their extension or content by default")
+	cmd.Flags().String("cache-control", "", "Cache-Control header served with the files")
+	cmd.Flags().String("content-encoding", "", "Content encoding of the files, eg. gzip")
+	cmd.Flags().String("storage-class", "", "Storage class of the objects, eg. NEARLINE on GCS")
+	cmd.Flags().StringToString("metadata", nil, "Custom metadata as key=value pairs, in extended attributes for local files")
+}
+
+// metadataFlags returns the metadata set by the metadata flags
+func metadataFlags(cmd *cobra.Command) filesys.Metadata {
+	var md filesys.Metadata
+	md.ContentType, _ = cmd.Flags().GetString("content-type")
+	md.CacheControl, _ = cmd.Flags().GetString("cache-control")
+	md.ContentEncoding, _ = cmd.Flags().GetString("content-encoding")
+	md.StorageClass, _ = cmd.Flags().GetString("storage-class")
+	md.Custom, _ = cmd.Flags().GetStringToString("metadata")
+	return md
+}
+
+// writerOptions returns the copy options set by the metadata flags
+func writerOptions(cmd *cobra.Command) []filesys.CopyOption {
+	md := metadataFlags(cmd)
+	if md.IsZero() {
+		return nil
+	}
+	return []filesys.CopyOption{filesys.WithWriterOptions(filesys.WithMetadata(md))}
+}
+
+func init() {
+	addMetadataFlags(metaSetCmd)
+	metaCmd.AddCommand(metaGetCmd, metaSetCmd)
+	RootCmd.AddCommand(metaCmd)
+}
@@ -11,18 +11,33 @@ var mkdirCmd = &cobra.Command{
 	Short: "Create a directory",
 	Long:  `Create a directory`,
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		verbose, _ := cmd.Flags().GetBool("verbose")
 		logger := NewLogger(verbose)
 		logger.Debug("Creating directory", args[0], "...")
 		uri, err := filesys.ParseURI(args[0])
-		fatalIfError(err)
-		_, err = filesys.MkDir(uri)
-		fatalIfError(err)
+		if err != nil {
+			return err
+		}
+		client, err := newClient(cmd)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		if dryRun(cmd) {
+			plan, err := client.PlanMkDir(cmd.Context(), uri)
+			if err != nil {
+				return err
+			}
+			return printPlan(cmd, plan, logger)
+		}
+		_, err = client.MkDir(cmd.Context(), uri)
+		return err
 	},
 }
 
 func init() {
 	mkdirCmd.Flags().BoolP("recursive", "r", false, "Create parent directories if they do not exist")
+	addPlanFlags(mkdirCmd)
 	RootCmd.AddCommand(mkdirCmd)
 }
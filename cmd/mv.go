@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"log"
+	"errors"
 
 	"github.com/spf13/cobra"
 
@@ -11,40 +11,84 @@ import (
 var mvCmd = &cobra.Command{
 	Use:   "mv [source] [dest]",
 	Short: "Move files from source to destination",
-	Long:  `Move files from source to destination`,
-	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Move files from source to destination.
+
+Within one file system the files are renamed, or rewritten server side on
+Google Cloud Storage, otherwise they are copied then deleted.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		recursive, _ := cmd.Flags().GetBool("recursive")
 		verbose, _ := cmd.Flags().GetBool("verbose")
 		logger := NewLogger(verbose)
 
-		source, dest := validateArgs(args)
+		source, dest, err := validateArgs(args)
+		if err != nil {
+			return err
+		}
 		srcURI, err := filesys.ParseURI(source)
-		fatalIfError(err)
+		if err != nil {
+			return err
+		}
 		destURI, err := filesys.ParseURI(dest)
-		fatalIfError(err)
+		if err != nil {
+			return err
+		}
 
-		filesys.Move(srcURI, destURI, recursive)
+		filter, err := filterOptions(cmd)
+		if err != nil {
+			return err
+		}
+		client, err := newClient(cmd)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		srcs, err := expandURI(cmd, client, srcURI, logger)
+		if err != nil {
+			return err
+		}
+		var opts []filesys.CopyOption
+		done := func(error) {}
+		if !dryRun(cmd) {
+			if opts, done, err = transferOptions(cmd, srcURI, destURI, logger); err != nil {
+				return err
+			}
+		}
+		err = transferMatches(cmd, client, srcs, destURI, logger,
+			func(src, dst filesys.URI) (filesys.Plan, error) {
+				return client.PlanMove(cmd.Context(), src, dst, recursive, filesys.WithFilter(filter))
+			},
+			func(src, dst filesys.URI) error {
+				return client.Move(cmd.Context(), src, dst, recursive, opts...)
+			})
+		done(err)
+		if err != nil {
+			return err
+		}
 		logger.Debug("File moved")
+		return nil
 	},
 }
 
-func validateArgs(args []string) (string, string) {
+func validateArgs(args []string) (string, string, error) {
 	if len(args) != 2 {
-		log.Fatal("source and destination are required")
+		return "", "", errors.New("source and destination are required")
 	}
 	source := args[0]
 	if source == "" {
-		log.Fatal("source is required")
+		return "", "", errors.New("source is required")
 	}
 
 	destination := args[1]
 	if destination == "" {
-		log.Fatal("destination is required")
+		return "", "", errors.New("destination is required")
 	}
-	return source, destination
+	return source, destination, nil
 }
 
 func init() {
+	mvCmd.Flags().BoolP("recursive", "r", false, "Move directories recursively")
+	addTransferFlags(mvCmd)
+	addPlanFlags(mvCmd)
 	RootCmd.AddCommand(mvCmd)
 }
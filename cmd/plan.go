@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/B87/file-bridge/pkg/filesys"
+)
+
+// addPlanFlags adds the flags printing the plan of a command instead of running it
+func addPlanFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("dry-run", "n", false, "Print what would change without changing anything")
+	cmd.Flags().Bool("json", false, "Print the dry run plan as JSON")
+}
+
+// dryRun reports whether the command only prints its plan
+func dryRun(cmd *cobra.Command) bool {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	return dryRun
+}
+
+// printPlan prints the actions of plan and their totals, or the whole plan as JSON with --json
+func printPlan(cmd *cobra.Command, plan filesys.Plan, logger *Logger) error {
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+	for _, action := range plan.Actions {
+		if action.IsDir {
+			logger.Printf("%-9s %s", action.Op, action.URI)
+		} else {
+			logger.Printf("%-9s %s (%d bytes)", action.Op, action.URI, action.Size)
+		}
+	}
+	logger.Printf("Dry run: %d to create, %d to overwrite, %d to delete, %d directories, %d bytes to write, %d bytes to delete",
+		plan.Creates, plan.Overwrites, plan.Deletes, plan.MkDirs, plan.Bytes, plan.DeletedBytes)
+	return nil
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/B87/file-bridge/pkg/filesys"
+)
+
+// progressInterval is the minimum time between two renderings of the progress bar
+const progressInterval = 200 * time.Millisecond
+
+// addProgressFlags adds the flag showing the progress of the transfer commands
+func addProgressFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("progress", true, "Show a live progress bar when the output is a terminal")
+}
+
+/*
+progress renders the progress events of a transfer as a bar with its
+throughput and ETA, and counts them for the final summary.
+*/
+type progress struct {
+	mu    sync.Mutex
+	out   io.Writer
+	live  bool
+	start time.Time
+	last  time.Time
+	// files and total are the files and bytes queued so far
+	files, done, failed int
+	total, bytes        int64
+	// copying holds the bytes read of the files being copied, by destination
+	copying map[string]int64
+}
+
+/*
+startProgress returns the option reporting the progress of a transfer,
+and the function printing its summary once the transfer is over.
+*/
+func startProgress(cmd *cobra.Command, logger *Logger) (filesys.CopyOption, func(error)) {
+	show, _ := cmd.Flags().GetBool("progress")
+	p := newProgress(os.Stderr, show && isTerminal(os.Stderr))
+	return filesys.WithProgress(p.event), func(err error) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.live {
+			p.render()
+			fmt.Fprintln(p.out)
+		}
+		elapsed := time.Since(p.start)
+		logger.Printf("Transferred %s in %d files, %d failed, in %s (%s/s)",
+			formatBytes(p.bytes), p.done, p.failed, elapsed.Round(time.Millisecond), formatBytes(rate(p.bytes, elapsed)))
+	}
+}
+
+func newProgress(out io.Writer, live bool) *progress {
+	return &progress{out: out, live: live, start: time.Now(), copying: map[string]int64{}}
+}
+
+func (p *progress) event(e filesys.ProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dst := e.Dst.String()
+	switch e.Type {
+	case filesys.ProgressQueued:
+		p.files++
+		p.total += e.Size
+	case filesys.ProgressBytes:
+		p.bytes += e.Bytes
+		p.copying[dst] += e.Bytes
+	case filesys.ProgressRetry:
+		// The bytes of the failed attempt are read again
+		p.bytes -= p.copying[dst]
+		delete(p.copying, dst)
+	case filesys.ProgressDone:
+		p.done++
+		delete(p.copying, dst)
+	case filesys.ProgressFailed:
+		p.failed++
+		delete(p.copying, dst)
+	}
+	if p.live && time.Since(p.last) >= progressInterval {
+		p.render()
+	}
+}
+
+// render rewrites the progress line, p.mu must be held
+func (p *progress) render() {
+	p.last = time.Now()
+	elapsed := time.Since(p.start)
+	ratio := 0.0
+	if p.total > 0 {
+		ratio = min(float64(p.bytes)/float64(p.total), 1)
+	}
+	const width = 30
+	filled := int(ratio * width)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+	eta := "-"
+	if speed := rate(p.bytes, elapsed); speed > 0 && p.total > p.bytes {
+		eta = (time.Duration((p.total-p.bytes)/speed) * time.Second).String()
+	}
+	fmt.Fprintf(p.out, "\r[%s] %3.0f%% %s / %s %s/s ETA %s %d/%d files\033[K",
+		bar, ratio*100, formatBytes(p.bytes), formatBytes(p.total), formatBytes(rate(p.bytes, elapsed)), eta,
+		p.done+p.failed, p.files)
+}
+
+// rate returns the bytes per second of n bytes in elapsed
+func rate(n int64, elapsed time.Duration) int64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(n) / elapsed.Seconds())
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/B87/file-bridge/pkg/filesys"
+)
+
+func TestProgressRetry(t *testing.T) {
+	p := newProgress(io.Discard, false)
+	a := filesys.ProgressEvent{Src: filesys.NewURI(filesys.MemScheme, "/a"), Dst: filesys.NewURI(filesys.MemScheme, "/dst/a"), Size: 10}
+	b := filesys.ProgressEvent{Src: filesys.NewURI(filesys.MemScheme, "/b"), Dst: filesys.NewURI(filesys.MemScheme, "/dst/b"), Size: 5}
+	send := func(e filesys.ProgressEvent, typ filesys.ProgressType, n int64) {
+		e.Type, e.Bytes = typ, n
+		p.event(e)
+	}
+	send(a, filesys.ProgressQueued, 0)
+	send(b, filesys.ProgressQueued, 0)
+	send(a, filesys.ProgressBytes, 6)
+	send(b, filesys.ProgressBytes, 5)
+	send(b, filesys.ProgressDone, 0)
+	// a fails after 6 bytes, then is read again from the start
+	send(a, filesys.ProgressRetry, 0)
+	assert.Equal(t, int64(5), p.bytes)
+	send(a, filesys.ProgressBytes, 10)
+	send(a, filesys.ProgressDone, 0)
+	assert.Equal(t, int64(15), p.bytes)
+	assert.Equal(t, int64(15), p.total)
+	assert.Equal(t, 2, p.done)
+	assert.Empty(t, p.copying)
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"errors"
+
 	"github.com/spf13/cobra"
 
 	"github.com/B87/file-bridge/pkg/filesys"
@@ -9,21 +11,63 @@ import (
 var rmCmd = &cobra.Command{
 	Use:   "rm [file]",
 	Short: "Remove a file",
-	Long:  `Remove a file`,
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `
+Remove a file, or every file matching a glob pattern:
+
+  filer rm tmp/file.txt
+  filer rm -r gs://bucket/tmp
+  filer rm 'logs/**/2023-*.gz'
+  filer rm -r --max-age 30d --include '*.gz' logs
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		recursive, _ := cmd.Flags().GetBool("recursive")
 		verbose, _ := cmd.Flags().GetBool("verbose")
 		logger := NewLogger(verbose)
 		logger.Debug("Removing", args[0], "...")
 		uri, err := filesys.ParseURI(args[0])
-		fatalIfError(err)
-		err = filesys.Delete(uri, recursive)
-		fatalIfError(err)
+		if err != nil {
+			return err
+		}
+		f, err := filterOptions(cmd)
+		if err != nil {
+			return err
+		}
+		filter := filesys.WithDeleteFilter(f)
+		client, err := newClient(cmd)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		uris, err := expandURI(cmd, client, uri, logger)
+		if err != nil {
+			return err
+		}
+		if dryRun(cmd) {
+			var plan filesys.Plan
+			for _, uri := range uris {
+				p, err := client.PlanDelete(cmd.Context(), uri, recursive, filter)
+				if err != nil {
+					return err
+				}
+				plan.Merge(p)
+			}
+			return printPlan(cmd, plan, logger)
+		}
+		var errs []error
+		for _, uri := range uris {
+			if err := client.Delete(cmd.Context(), uri, recursive, filter); err != nil {
+				logger.Debugf("%s: %v", uri, err)
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
 	},
 }
 
 func init() {
 	rmCmd.Flags().BoolP("recursive", "r", false, "Remove directories and their contents recursively")
+	addPlanFlags(rmCmd)
+	addFilterFlags(rmCmd)
 	RootCmd.AddCommand(rmCmd)
 }
@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/B87/file-bridge/pkg/filesys"
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -14,19 +20,93 @@ var RootCmd = &cobra.Command{
 	Long: `
 File Bridge CLI interacts with files across file systems.
 `,
+	// The arguments are valid, an error of the command does not need the usage
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cmd.SilenceUsage = true
+	},
+	SilenceErrors: true,
 }
 
-func Execute() {
-	err := RootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
+/*
+Execute runs the root command with a context that is canceled on SIGINT,
+so running operations can stop and clean up before exiting.
+
+returns the exit code of the command error, see exitCode, for main to exit with
+*/
+func Execute() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := RootCmd.ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitCode(err)
 	}
+	return 0
 }
 
-func fatalIfError(err error) {
+// newClient creates a filesys client configured from the global flags
+func newClient(cmd *cobra.Command) (*filesys.Client, error) {
+	credentials, _ := cmd.Flags().GetString("credentials")
+	project, _ := cmd.Flags().GetString("project")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	retries, _ := cmd.Flags().GetInt("retries")
+	logger := NewLogger(verbose)
+	retry := filesys.DefaultRetryPolicy
+	retry.Attempts = retries + 1
+	retry.OnRetry = func(attempt int, err error, wait time.Duration) {
+		logger.Debugf("Retry %d/%d in %s: %v", attempt, retries, wait.Round(time.Millisecond), err)
+	}
+	inPlace, _ := cmd.Flags().GetBool("inplace")
+	symlinks, _ := cmd.Flags().GetString("symlinks")
+	policy, err := filesys.ParseSymlinkPolicy(symlinks)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, err
+	}
+	return filesys.NewClient(
+		filesys.WithCredentialsFile(credentials),
+		filesys.WithProject(project),
+		filesys.WithRetryPolicy(retry),
+		filesys.WithInPlace(inPlace),
+		filesys.WithSymlinks(policy),
+	), nil
+}
+
+// Exit codes returned by fileb depending on the error kind
+const (
+	ExitError         = 1
+	ExitNotFound      = 3
+	ExitPermission    = 4
+	ExitAlreadyExists = 5
+	ExitPrecondition  = 6
+	ExitRateLimited   = 7
+	ExitTransient     = 8
+	ExitChecksum      = 9
+	ExitNoSpace       = 10
+	ExitInterrupted   = 130
+)
+
+// exitCode returns the exit code matching the kind of err
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.Is(err, filesys.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, filesys.ErrPermission):
+		return ExitPermission
+	case errors.Is(err, filesys.ErrAlreadyExists):
+		return ExitAlreadyExists
+	case errors.Is(err, filesys.ErrPrecondition):
+		return ExitPrecondition
+	case errors.Is(err, filesys.ErrRateLimited):
+		return ExitRateLimited
+	case errors.Is(err, filesys.ErrTransient):
+		return ExitTransient
+	case errors.Is(err, filesys.ErrChecksumMismatch):
+		return ExitChecksum
+	case errors.Is(err, filesys.ErrNoSpace):
+		return ExitNoSpace
+	default:
+		return ExitError
 	}
 }
 
@@ -78,4 +158,7 @@ func (l *Logger) PrintDebugGlobal() {
 
 func init() {
 	RootCmd.PersistentFlags().BoolP("verbose", "v", false, "Verbose, default false")
+	RootCmd.PersistentFlags().String("credentials", "", "Service account key file, default credentials are used if empty")
+	RootCmd.PersistentFlags().String("project", "", "Cloud project billed for requests")
+	RootCmd.PersistentFlags().Int("retries", filesys.DefaultRetryPolicy.Attempts-1, "Retries of an operation failing with a transient error, 0 disables them")
 }
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteExitCode(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644))
+	defer RootCmd.SetArgs(nil)
+
+	for _, tc := range []struct {
+		args []string
+		code int
+	}{
+		{args: []string{"mkdir", filepath.Join(dir, "new")}, code: 0},
+		{args: []string{"ls", filepath.Join(dir, "missing")}, code: ExitNotFound},
+		{args: []string{"ls", filepath.Join(dir, "*.zz")}, code: ExitNotFound},
+		{args: []string{"mkdir", filepath.Join(dir, "a.txt")}, code: ExitAlreadyExists},
+		{args: []string{"sync", filepath.Join(dir, "a.*"), filepath.Join(dir, "synced.txt")}, code: 0},
+		{args: []string{"sync", filepath.Join(dir, "*.txt"), filepath.Join(dir, "new")}, code: ExitError},
+		{args: []string{"sync", filepath.Join(dir, "*.zz"), filepath.Join(dir, "new")}, code: ExitNotFound},
+	} {
+		// A command keeps the context of its first run, canceled once Execute returns
+		for _, c := range RootCmd.Commands() {
+			c.SetContext(context.Background())
+		}
+		RootCmd.SetArgs(tc.args)
+		assert.Equal(t, tc.code, Execute(), "%v", tc.args)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "synced.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(b))
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/B87/file-bridge/pkg/filesys"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [source] [destination]",
+	Short: "Mirror source to destination, copying only new and changed files",
+	Long: `
+Mirror the content of source to destination, copying only new and changed files.
+Files are compared by size and modification time, or by checksum with --checksum.
+A source pattern must match a single file or directory, use --include to
+sync some files of a directory:
+
+  filer sync tmp gs://bucket/tmp
+  filer sync --delete --dry-run gs://bucket/tmp tmp
+  filer sync 'gs://bucket/backup-2024*' tmp/backup
+  filer sync --exclude-from excludes.txt --min-size 1K tmp gs://bucket/tmp
+`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		parallel, _ := cmd.Flags().GetInt("parallel")
+		checksum, _ := cmd.Flags().GetBool("checksum")
+		del, _ := cmd.Flags().GetBool("delete")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		verify, _ := cmd.Flags().GetBool("verify")
+		preserve, _ := cmd.Flags().GetBool("preserve")
+		logger := NewLogger(verbose)
+
+		source, dest, err := validateArgs(args)
+		if err != nil {
+			return err
+		}
+		srcURI, err := filesys.ParseURI(source)
+		if err != nil {
+			return err
+		}
+		dstURI, err := filesys.ParseURI(dest)
+		if err != nil {
+			return err
+		}
+		filter, err := filterOptions(cmd)
+		if err != nil {
+			return err
+		}
+		bandwidth, err := bandwidthOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		opts := []filesys.SyncOption{
+			filesys.WithCopyOptions(filesys.WithParallel(parallel), filesys.WithFilter(filter)),
+			filesys.WithCopyOptions(bandwidth...),
+			filesys.WithCopyOptions(writerOptions(cmd)...),
+		}
+		if checksum {
+			opts = append(opts, filesys.WithChecksum())
+		}
+		if del {
+			opts = append(opts, filesys.WithDelete())
+		}
+		if dryRun {
+			opts = append(opts, filesys.WithSyncDryRun())
+		}
+		if verify {
+			opts = append(opts, filesys.WithCopyOptions(filesys.WithVerify("")))
+		}
+		if preserve {
+			opts = append(opts, filesys.WithCopyOptions(filesys.WithPreserve()))
+		}
+
+		client, err := newClient(cmd)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		srcs, err := expandURI(cmd, client, srcURI, logger)
+		if err != nil {
+			return err
+		}
+		if len(srcs) > 1 {
+			return fmt.Errorf("%w : %s matches %d sources, sync takes a single one", filesys.ErrInvalidURI, srcURI, len(srcs))
+		}
+
+		done := func(error) {}
+		if !dryRun {
+			var progressOpt filesys.CopyOption
+			progressOpt, done = startProgress(cmd, logger)
+			opts = append(opts, filesys.WithCopyOptions(progressOpt))
+		}
+
+		summary, err := client.Sync(cmd.Context(), srcs[0], dstURI, opts...)
+		done(err)
+		for _, action := range summary.Actions {
+			if dryRun {
+				logger.Printf("%s %s", action.Op, action.Dst)
+			} else {
+				logger.Debugf("%s %s", action.Op, action.Dst)
+			}
+		}
+		prefix := ""
+		if dryRun {
+			prefix = "Dry run: "
+		}
+		logger.Printf("%s%d created, %d updated, %d deleted, %d unchanged, %d bytes copied",
+			prefix, summary.Created, summary.Updated, summary.Deleted, summary.Unchanged, summary.Bytes)
+		return err
+	},
+}
+
+func init() {
+	syncCmd.Flags().IntP("parallel", "p", filesys.DefaultParallel, "Number of files transferred at once")
+	syncCmd.Flags().BoolP("checksum", "c", false, "Compare files by checksum instead of size and modification time")
+	syncCmd.Flags().Bool("delete", false, "Delete destination files missing from the source")
+	syncCmd.Flags().BoolP("dry-run", "n", false, "Print the actions without running them")
+	syncCmd.Flags().Bool("verify", false, "Verify the checksum of every copied file")
+	syncCmd.Flags().Bool("preserve", false, "Keep the modification time, permissions and owner of the files, in object metadata on GCS")
+	addFilterFlags(syncCmd)
+	addProgressFlags(syncCmd)
+	addBandwidthFlags(syncCmd)
+	addInPlaceFlag(syncCmd)
+	addSymlinkFlag(syncCmd)
+	addMetadataFlags(syncCmd)
+	RootCmd.AddCommand(syncCmd)
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/B87/file-bridge/pkg/filesys"
+)
+
+// addTransferFlags adds the flags shared by the commands copying files
+func addTransferFlags(cmd *cobra.Command) {
+	cmd.Flags().IntP("parallel", "p", filesys.DefaultParallel, "Number of files transferred at once between file systems")
+	cmd.Flags().Bool("resume", false, "Journal the transfer, resuming it from its journal if it was interrupted")
+	cmd.Flags().String("journal", "", "Journal the transfer to this file, default with --resume is in the user cache directory")
+	cmd.Flags().Bool("verify", false, "Verify the checksum of every copied file")
+	cmd.Flags().String("checksum", "", "Verification algorithm: md5, crc32c or sha256, default depends on the destination")
+	cmd.Flags().Bool("preserve", false, "Keep the modification time, permissions and owner of the files, in object metadata on GCS")
+	addFilterFlags(cmd)
+	addProgressFlags(cmd)
+	addBandwidthFlags(cmd)
+	addInPlaceFlag(cmd)
+	addSymlinkFlag(cmd)
+	addMetadataFlags(cmd)
+}
+
+// addInPlaceFlag adds the flag writing local files in place, read by newClient
+func addInPlaceFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("inplace", false, "Write local files directly to their path instead of a temporary file renamed once complete")
+}
+
+// addSymlinkFlag adds the flag choosing how local symbolic links are handled, read by newClient
+func addSymlinkFlag(cmd *cobra.Command) {
+	cmd.Flags().String("symlinks", string(filesys.SymlinkFollow), "Local symbolic links: follow, preserve as links, text to copy their target path, or skip")
+}
+
+// addBandwidthFlags adds the flag limiting the bandwidth of the transfer commands
+func addBandwidthFlags(cmd *cobra.Command) {
+	cmd.Flags().String("bwlimit", "", `Bandwidth limit per second, eg. 10M, or a schedule like "08:00,5M 20:00,off"`)
+}
+
+// bandwidthOptions returns the copy options set by the bandwidth flags
+func bandwidthOptions(cmd *cobra.Command) ([]filesys.CopyOption, error) {
+	limit, _ := cmd.Flags().GetString("bwlimit")
+	if limit == "" {
+		return nil, nil
+	}
+	schedule, err := filesys.ParseBandwidth(limit)
+	if err != nil {
+		return nil, err
+	}
+	return []filesys.CopyOption{filesys.WithBandwidthLimiter(filesys.NewBandwidthLimiter(schedule))}, nil
+}
+
+/*
+transferOptions returns the copy options set by the transfer flags.
+
+The transfer is only journaled with --resume or --journal, so it can be
+resumed with --resume. The returned function must be called with the
+transfer error to print its summary and remove the journal once the
+transfer succeeded.
+*/
+func transferOptions(cmd *cobra.Command, src, dst filesys.URI, logger *Logger) ([]filesys.CopyOption, func(error), error) {
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	filter, err := filterOptions(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	bandwidth, err := bandwidthOptions(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	journal, err := transferJournal(cmd, src, dst)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := []filesys.CopyOption{
+		filesys.WithParallel(parallel),
+		filesys.WithFilter(filter),
+	}
+	if journal != nil {
+		logger.Debugf("Journal: %s", journal.Path())
+		opts = append(opts, filesys.WithJournal(journal))
+	}
+	progressOpt, summary := startProgress(cmd, logger)
+	opts = append(opts, progressOpt)
+	opts = append(opts, bandwidth...)
+	opts = append(opts, writerOptions(cmd)...)
+	if preserve, _ := cmd.Flags().GetBool("preserve"); preserve {
+		opts = append(opts, filesys.WithPreserve())
+	}
+	if verify, _ := cmd.Flags().GetBool("verify"); verify {
+		checksum, _ := cmd.Flags().GetString("checksum")
+		opts = append(opts, filesys.WithVerify(filesys.HashAlgorithm(checksum)))
+	}
+	return opts, func(err error) {
+		summary(err)
+		switch {
+		case journal == nil:
+		case err != nil:
+			journal.Close()
+			if cmd.Flags().Changed("journal") {
+				logger.Printf("Run again with --resume --journal %s to continue the transfer", journal.Path())
+			} else {
+				logger.Printf("Run again with --resume to continue the transfer")
+			}
+		default:
+			if err := journal.Remove(); err != nil {
+				logger.Debugf("Removing journal: %v", err)
+			}
+		}
+	}, nil
+}
+
+/*
+transferJournal returns the journal of the transfer, nil without --resume nor --journal.
+
+--resume opens the journal of an interrupted transfer, at the default path
+or the one of --journal, --journal alone starts a new one.
+*/
+func transferJournal(cmd *cobra.Command, src, dst filesys.URI) (*filesys.Journal, error) {
+	resume, _ := cmd.Flags().GetBool("resume")
+	path, _ := cmd.Flags().GetString("journal")
+	if !resume && path == "" {
+		return nil, nil
+	}
+	if path == "" {
+		var err error
+		if path, err = filesys.JournalPath(src, dst); err != nil {
+			return nil, err
+		}
+	}
+	if resume {
+		return filesys.OpenJournal(path)
+	}
+	return filesys.CreateJournal(path)
+}
@@ -1,7 +1,11 @@
 package main
 
-import "github.com/B87/file-bridge/cmd"
+import (
+	"os"
+
+	"github.com/B87/file-bridge/cmd"
+)
 
 func main() {
-	cmd.RootCmd.Execute()
+	os.Exit(cmd.Execute())
 }
@@ -0,0 +1,201 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidSize      = errors.New("invalid size")
+	ErrInvalidBandwidth = errors.New("invalid bandwidth limit")
+)
+
+/*
+ParseSize parses a size in bytes with an optional K, M, G or T binary unit,
+eg. 512, 10K or 1.5M. A trailing B is ignored.
+*/
+func ParseSize(s string) (int64, error) {
+	units := map[byte]int64{'K': 1 << 10, 'M': 1 << 20, 'G': 1 << 30, 'T': 1 << 40}
+	num, unit := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B"), int64(1)
+	if n := len(num); n > 0 && units[num[n-1]] != 0 {
+		num, unit = num[:n-1], units[num[n-1]]
+	}
+	value, err := strconv.ParseFloat(num, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%w : %q", ErrInvalidSize, s)
+	}
+	return int64(value * float64(unit)), nil
+}
+
+// BandwidthSlot is a bandwidth limit starting at a time of the day
+type BandwidthSlot struct {
+	// Start is the time of the day the slot starts at, from midnight in local time
+	Start time.Duration
+	// Rate is the limit in bytes per second, 0 is unlimited
+	Rate int64
+}
+
+/*
+BandwidthSchedule is a list of slots sorted by start time. The last slot
+of a day lasts until the first one of the next day. An empty schedule
+is unlimited.
+*/
+type BandwidthSchedule []BandwidthSlot
+
+/*
+ParseBandwidth parses a bandwidth limit: a rate per second like 10M, off,
+or a schedule of space separated "HH:MM,rate" slots like "08:00,5M 20:00,off".
+*/
+func ParseBandwidth(s string) (BandwidthSchedule, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 1 && !strings.Contains(fields[0], ",") {
+		rate, err := parseRate(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		return BandwidthSchedule{{Rate: rate}}, nil
+	}
+	var schedule BandwidthSchedule
+	for _, field := range fields {
+		at, rate, ok := strings.Cut(field, ",")
+		if !ok {
+			return nil, fmt.Errorf("%w : %q is not HH:MM,rate", ErrInvalidBandwidth, field)
+		}
+		start, err := time.Parse("15:04", at)
+		if err != nil {
+			return nil, fmt.Errorf("%w : %q is not HH:MM", ErrInvalidBandwidth, at)
+		}
+		slot := BandwidthSlot{Start: time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute}
+		if slot.Rate, err = parseRate(rate); err != nil {
+			return nil, err
+		}
+		schedule = append(schedule, slot)
+	}
+	sort.SliceStable(schedule, func(i, j int) bool { return schedule[i].Start < schedule[j].Start })
+	return schedule, nil
+}
+
+func parseRate(s string) (int64, error) {
+	if strings.EqualFold(s, "off") {
+		return 0, nil
+	}
+	rate, err := ParseSize(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w : %w", ErrInvalidBandwidth, err)
+	}
+	return rate, nil
+}
+
+// Rate returns the limit in bytes per second at t, 0 is unlimited
+func (s BandwidthSchedule) Rate(t time.Time) int64 {
+	if len(s) == 0 {
+		return 0
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	now := t.Sub(midnight)
+	rate := s[len(s)-1].Rate
+	for _, slot := range s {
+		if slot.Start > now {
+			break
+		}
+		rate = slot.Rate
+	}
+	return rate
+}
+
+/*
+BandwidthLimiter limits the throughput of the streams sharing it,
+following a schedule.
+
+Each read reserves its bytes after the ones of the previous reads, the
+streams are served in turn and share the bandwidth fairly.
+It is safe for concurrent use.
+*/
+type BandwidthLimiter struct {
+	mu       sync.Mutex
+	schedule BandwidthSchedule
+	// next is when the bytes reserved so far are sent at the limit rate
+	next time.Time
+}
+
+// NewBandwidthLimiter returns a limiter following schedule
+func NewBandwidthLimiter(schedule BandwidthSchedule) *BandwidthLimiter {
+	return &BandwidthLimiter{schedule: schedule}
+}
+
+// reserve reserves n bytes and returns when they are within the limit
+func (l *BandwidthLimiter) reserve(n int) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	rate := l.schedule.Rate(now)
+	if l.next.Before(now) || rate == 0 {
+		l.next = now
+	}
+	if rate > 0 {
+		l.next = l.next.Add(time.Duration(float64(n) / float64(rate) * float64(time.Second)))
+	}
+	return l.next
+}
+
+// chunk returns how many bytes a read takes at most, a tenth of a second at the current rate
+func (l *BandwidthLimiter) chunk() int {
+	rate := l.schedule.Rate(time.Now())
+	if rate == 0 {
+		return 0
+	}
+	return int(max(rate/10, 512))
+}
+
+// Reader returns r limited by l, a wait is cut short once ctx is done
+func (l *BandwidthLimiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &limitedReader{ctx: ctx, r: r, limiter: l}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *BandwidthLimiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if chunk := lr.limiter.chunk(); chunk > 0 && len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := lr.r.Read(p)
+	if n == 0 {
+		return n, err
+	}
+	wait := time.Until(lr.limiter.reserve(n))
+	if wait <= 0 {
+		return n, err
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return n, err
+	case <-lr.ctx.Done():
+		return n, lr.ctx.Err()
+	}
+}
+
+/*
+WithBandwidthLimit limits copies to bytesPerSecond, shared by all their
+workers. See WithBandwidthLimiter for a schedule.
+*/
+func WithBandwidthLimit(bytesPerSecond int64) CopyOption {
+	return WithBandwidthLimiter(NewBandwidthLimiter(BandwidthSchedule{{Rate: bytesPerSecond}}))
+}
+
+// WithBandwidthLimiter reads the source of every copied file through l
+func WithBandwidthLimiter(l *BandwidthLimiter) CopyOption {
+	return func(o *CopyOptions) { o.Bandwidth = l }
+}
@@ -0,0 +1,104 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSize(t *testing.T) {
+	for s, size := range map[string]int64{"512": 512, "10K": 10 << 10, "1.5m": 3 << 19, "2GB": 2 << 30, "1T": 1 << 40} {
+		n, err := ParseSize(s)
+		assert.NoError(t, err)
+		assert.Equal(t, size, n, s)
+	}
+	for _, s := range []string{"", "M", "-1K", "10X"} {
+		_, err := ParseSize(s)
+		assert.ErrorIs(t, err, ErrInvalidSize, s)
+	}
+}
+
+func TestParseBandwidth(t *testing.T) {
+	schedule, err := ParseBandwidth("10M")
+	assert.NoError(t, err)
+	assert.Equal(t, BandwidthSchedule{{Rate: 10 << 20}}, schedule)
+
+	schedule, err = ParseBandwidth("off")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), schedule.Rate(time.Now()))
+
+	schedule, err = ParseBandwidth("20:00,off 08:00,5M 12:30,1M")
+	assert.NoError(t, err)
+	assert.Equal(t, BandwidthSchedule{
+		{Start: 8 * time.Hour, Rate: 5 << 20},
+		{Start: 12*time.Hour + 30*time.Minute, Rate: 1 << 20},
+		{Start: 20 * time.Hour},
+	}, schedule)
+	at := func(hour, minute int) time.Time { return time.Date(2024, 1, 1, hour, minute, 0, 0, time.Local) }
+	assert.Equal(t, int64(0), schedule.Rate(at(3, 0)), "the last slot goes on after midnight")
+	assert.Equal(t, int64(5<<20), schedule.Rate(at(8, 0)))
+	assert.Equal(t, int64(5<<20), schedule.Rate(at(12, 29)))
+	assert.Equal(t, int64(1<<20), schedule.Rate(at(12, 30)))
+	assert.Equal(t, int64(0), schedule.Rate(at(23, 59)))
+
+	for _, s := range []string{"fast", "8:00", "25:00,1M", "08:00,1X 10:00,off"} {
+		_, err := ParseBandwidth(s)
+		assert.ErrorIs(t, err, ErrInvalidBandwidth, s)
+	}
+}
+
+func TestBandwidthLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewBandwidthLimiter(BandwidthSchedule{{Rate: 100 << 10}})
+	start := time.Now()
+
+	// Two streams share the limit, 40K at 100K/s take 0.4s
+	var wg sync.WaitGroup
+	ends := make([]time.Duration, 2)
+	for i := range ends {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n, err := io.Copy(io.Discard, limiter.Reader(ctx, bytes.NewReader(make([]byte, 20<<10))))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(20<<10), n)
+			ends[i] = time.Since(start)
+		}(i)
+	}
+	wg.Wait()
+	for _, end := range ends {
+		assert.Greater(t, end, 300*time.Millisecond, "each stream gets half of the bandwidth")
+		assert.Less(t, end, 2*time.Second)
+	}
+
+	// An unlimited slot does not wait
+	unlimited := NewBandwidthLimiter(nil)
+	start = time.Now()
+	_, err := io.Copy(io.Discard, unlimited.Reader(ctx, bytes.NewReader(make([]byte, 1<<20))))
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+	// A wait stops with the context
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	slow := NewBandwidthLimiter(BandwidthSchedule{{Rate: 1 << 10}})
+	_, err = io.Copy(io.Discard, slow.Reader(ctx, bytes.NewReader(make([]byte, 10<<10))))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCopyBandwidthLimit(t *testing.T) {
+	ctx := context.Background()
+	src := NewMemFS(0)
+	writeMemFile(t, src, "/a.txt", string(make([]byte, 20<<10)))
+	dst := NewMemFS(0)
+	start := time.Now()
+	opts := newCopyOptions([]CopyOption{WithBandwidthLimit(100 << 10)})
+	assert.NoError(t, copyNode(ctx, mustGet(t, src, "/a.txt"), memURI("/b.txt"), src, dst, opts))
+	assert.Greater(t, time.Since(start), 150*time.Millisecond)
+	assert.Len(t, readMemFile(t, dst, "/b.txt"), 20<<10)
+}
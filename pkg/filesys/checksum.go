@@ -0,0 +1,101 @@
+package filesys
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// HashAlgorithm identifies a checksum algorithm
+type HashAlgorithm string
+
+const (
+	MD5    HashAlgorithm = "md5"
+	CRC32C HashAlgorithm = "crc32c"
+	SHA256 HashAlgorithm = "sha256"
+)
+
+var ErrUnknownHash = errors.New("unknown hash algorithm")
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// NewHash returns a new hash.Hash computing the given algorithm
+func NewHash(algo HashAlgorithm) (hash.Hash, error) {
+	switch algo {
+	case MD5:
+		return md5.New(), nil
+	case CRC32C:
+		return crc32.New(crc32cTable), nil
+	case SHA256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("%w : %s", ErrUnknownHash, algo)
+	}
+}
+
+// HexChecksum returns the hex encoded checksum of the node for algo, or "" if unknown
+func (n Node) HexChecksum(algo HashAlgorithm) string {
+	sum, ok := n.Checksums[algo]
+	if !ok {
+		return ""
+	}
+	return hex.EncodeToString(sum)
+}
+
+/*
+Checksum returns the checksum of a file.
+
+The checksum stored by the backend is used when available,
+otherwise the file is read and hashed.
+*/
+func (c *Client) Checksum(ctx context.Context, uri URI, algo HashAlgorithm) ([]byte, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	fs, err := c.FS(ctx, uri.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return checksumFS(ctx, fs, uri, algo)
+}
+
+// Checksum returns the checksum of a file with the DefaultClient, see Client.Checksum
+func Checksum(ctx context.Context, uri URI, algo HashAlgorithm) ([]byte, error) {
+	return DefaultClient.Checksum(ctx, uri, algo)
+}
+
+func checksumFS(ctx context.Context, fs FS, uri URI, algo HashAlgorithm) ([]byte, error) {
+	node, err := fs.Get(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if sum, ok := node.Checksums[algo]; ok {
+		return sum, nil
+	}
+	r, err := fs.Reader(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return hashReader(ctx, r, algo)
+}
+
+// hashReader reads r until EOF and returns its checksum
+func hashReader(ctx context.Context, r io.Reader, algo HashAlgorithm) ([]byte, error) {
+	h, err := NewHash(algo)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := CopyContext(ctx, h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,207 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrClientClosed = errors.New("client closed")
+
+// Options configures the file systems created by a Client
+type Options struct {
+	// CredentialsFile is the path of a service account key file, default credentials are used if empty
+	CredentialsFile string
+	// Project is the cloud project billed for requests
+	Project string
+	// Endpoints overrides the API endpoint of a scheme, eg. to use a storage emulator
+	Endpoints map[string]string
+	// Concurrency is the maximum number of operations running at once on the client, 0 is unlimited
+	Concurrency int
+	// MaxMemorySize is the size in bytes the mem file system can hold, 0 is unlimited
+	MaxMemorySize int64
+	// InPlace makes the local file system write files directly to their path, see LocalFS
+	InPlace bool
+	// Symlinks is how the local file system handles symbolic links, SymlinkFollow by default
+	Symlinks SymlinkPolicy
+	// Retry is the retry policy of the operations and of each copied file, DefaultRetryPolicy by default
+	Retry RetryPolicy
+}
+
+// Option sets an optional parameter of a Client
+type Option func(*Options)
+
+// WithCredentialsFile sets the service account key file used to authenticate
+func WithCredentialsFile(path string) Option {
+	return func(o *Options) { o.CredentialsFile = path }
+}
+
+// WithProject sets the cloud project billed for requests
+func WithProject(project string) Option {
+	return func(o *Options) { o.Project = project }
+}
+
+// WithEndpoint overrides the API endpoint used for a scheme
+func WithEndpoint(scheme, endpoint string) Option {
+	return func(o *Options) {
+		if o.Endpoints == nil {
+			o.Endpoints = map[string]string{}
+		}
+		o.Endpoints[scheme] = endpoint
+	}
+}
+
+// WithMaxMemorySize limits the size in bytes of the files the mem file system can hold
+func WithMaxMemorySize(n int64) Option {
+	return func(o *Options) { o.MaxMemorySize = n }
+}
+
+/*
+WithInPlace makes the local file system write files directly to their path
+if inPlace is true, instead of a temporary file renamed once complete.
+*/
+func WithInPlace(inPlace bool) Option {
+	return func(o *Options) { o.InPlace = inPlace }
+}
+
+// WithConcurrency limits the number of operations running at once on the client
+func WithConcurrency(n int) Option {
+	return func(o *Options) { o.Concurrency = n }
+}
+
+/*
+Client runs file operations across file systems.
+
+File systems are created from the registered schemes with the client
+options and connected on first use, connections are kept open until Close.
+A Client is safe for concurrent use, several differently configured
+clients can be used in the same process.
+*/
+type Client struct {
+	opts Options
+	sem  chan struct{}
+
+	mu          sync.Mutex
+	filesystems map[string]*clientFS
+	closed      bool
+}
+
+// clientFS is a file system of a Client, fs and err are set once ready is closed
+type clientFS struct {
+	ready chan struct{}
+	fs    FS
+	err   error
+}
+
+// DefaultClient is the Client used by the package level functions
+var DefaultClient = NewClient()
+
+func NewClient(opts ...Option) *Client {
+	c := &Client{opts: Options{Retry: DefaultRetryPolicy}, filesystems: map[string]*clientFS{}}
+	for _, option := range opts {
+		option(&c.opts)
+	}
+	if c.opts.Concurrency > 0 {
+		c.sem = make(chan struct{}, c.opts.Concurrency)
+	}
+	return c
+}
+
+/*
+FS returns the connected file system of a scheme, connecting it on first use.
+
+The first caller starts its connection without locking the client, the
+concurrent callers of the same scheme wait for it. The connection is not
+canceled with ctx, a caller whose ctx is done stops waiting but the next
+ones get the connected file system. A failed connection is tried again
+by the next call.
+
+returns:
+  - ErrClientClosed if the client is closed
+  - ErrUnknownScheme if the scheme is not registered
+  - ErrConnecting if the file system fails to connect
+*/
+func (c *Client) FS(ctx context.Context, scheme string) (FS, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClientClosed
+	}
+	entry, ok := c.filesystems[scheme]
+	if !ok {
+		entry = &clientFS{ready: make(chan struct{})}
+		c.filesystems[scheme] = entry
+	}
+	c.mu.Unlock()
+	if !ok {
+		go c.connect(context.WithoutCancel(ctx), scheme, entry)
+	}
+	select {
+	case <-entry.ready:
+		return entry.fs, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// connect creates and connects the file system of entry, then marks it ready
+func (c *Client) connect(ctx context.Context, scheme string, entry *clientFS) {
+	fs, err := newSchemeFS(scheme, c.opts)
+	if err == nil {
+		err = connectFilesystems(ctx, fs)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case err != nil:
+		delete(c.filesystems, scheme)
+	case c.closed:
+		// Closed while connecting
+		disconnectFilesystems(fs)
+		err = ErrClientClosed
+	default:
+		entry.fs = fs
+	}
+	entry.err = err
+	close(entry.ready)
+}
+
+// Close disconnects every file system of the client, the client can not be used afterwards
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	var errs []error
+	for _, entry := range c.filesystems {
+		select {
+		case <-entry.ready:
+		default:
+			// Disconnected by connect once connected
+			continue
+		}
+		if entry.fs == nil {
+			continue
+		}
+		if err := disconnectFilesystems(entry.fs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	c.filesystems = nil
+	return errors.Join(errs...)
+}
+
+// acquire waits for an operation slot, the returned function releases it
+func (c *Client) acquire(ctx context.Context) (func(), error) {
+	if c.sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.sem <- struct{}{}:
+		return func() { <-c.sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
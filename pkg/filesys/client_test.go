@@ -0,0 +1,141 @@
+package filesys
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingFS is a NoopFS counting its connections
+type countingFS struct {
+	NoopFS
+	connects    *atomic.Int32
+	disconnects *atomic.Int32
+}
+
+func (fs countingFS) Connect(ctx context.Context) error {
+	fs.connects.Add(1)
+	return nil
+}
+
+func (fs countingFS) Disconnect() error {
+	fs.disconnects.Add(1)
+	return nil
+}
+
+func TestClientReusesConnections(t *testing.T) {
+	var connects, disconnects atomic.Int32
+	err := RegisterScheme("counting-test", func(Options) FS {
+		return countingFS{connects: &connects, disconnects: &disconnects}
+	})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	client := NewClient(WithConcurrency(2))
+	uri := NewURI("counting-test", "bucket/dir")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.List(ctx, uri, true)
+			assert.NoError(t, err)
+			assert.NoError(t, client.Delete(ctx, uri, true))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), connects.Load())
+	assert.Equal(t, int32(0), disconnects.Load())
+
+	assert.NoError(t, client.Close())
+	assert.Equal(t, int32(1), disconnects.Load())
+	_, err = client.List(ctx, uri, true)
+	assert.ErrorIs(t, err, ErrClientClosed)
+
+	// Clients do not share connections
+	other := NewClient()
+	defer other.Close()
+	_, err = other.MkDir(ctx, uri)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), connects.Load())
+}
+
+// blockingFS is a NoopFS whose connections wait for release
+type blockingFS struct {
+	NoopFS
+	connecting chan struct{}
+	release    chan struct{}
+}
+
+func (fs blockingFS) Connect(ctx context.Context) error {
+	fs.connecting <- struct{}{}
+	<-fs.release
+	return nil
+}
+
+func TestClientConnectUnlocked(t *testing.T) {
+	connecting, release := make(chan struct{}, 2), make(chan struct{})
+	err := RegisterScheme("blocking-test", func(Options) FS {
+		return blockingFS{connecting: connecting, release: release}
+	})
+	assert.NoError(t, err)
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+
+	// The first caller giving up does not cancel the connection
+	first, cancelFirst := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		_, err := client.FS(first, "blocking-test")
+		done <- err
+	}()
+	<-connecting
+	cancelFirst()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	go func() {
+		_, err := client.FS(ctx, "blocking-test")
+		done <- err
+	}()
+
+	// The other schemes are not held up, the same scheme waits for the connection
+	_, err = client.FS(ctx, MemScheme)
+	assert.NoError(t, err)
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = client.FS(timeout, "blocking-test")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	assert.NoError(t, <-done)
+	_, err = client.FS(ctx, "blocking-test")
+	assert.NoError(t, err)
+	assert.Empty(t, connecting)
+}
+
+func TestClientOptions(t *testing.T) {
+	client := NewClient(
+		WithCredentialsFile("key.json"),
+		WithProject("project"),
+		WithEndpoint(GCPBucketScheme, "http://localhost:4443/storage/v1/"),
+		WithConcurrency(4),
+	)
+	assert.Equal(t, "key.json", client.opts.CredentialsFile)
+	assert.Equal(t, "project", client.opts.Project)
+	assert.Equal(t, "http://localhost:4443/storage/v1/", client.opts.Endpoints[GCPBucketScheme])
+	assert.Equal(t, 4, cap(client.sem))
+	assert.Len(t, gcpClientOptions(client.opts), 3)
+}
+
+func TestClientUnknownScheme(t *testing.T) {
+	client := NewClient()
+	defer client.Close()
+	_, err := client.FS(context.Background(), "unregistered")
+	assert.ErrorIs(t, err, ErrUnknownScheme)
+}
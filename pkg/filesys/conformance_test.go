@@ -0,0 +1,51 @@
+package filesys_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/B87/file-bridge/pkg/filesys"
+	"github.com/B87/file-bridge/pkg/filesys/fstest"
+)
+
+func TestLocalFSConformance(t *testing.T) {
+	fstest.TestFS(t, func(t *testing.T) (filesys.FS, filesys.URI) {
+		return filesys.NewLocalFS(), filesys.NewURI(filesys.LocalScheme, t.TempDir())
+	})
+}
+
+func TestMemFSConformance(t *testing.T) {
+	fstest.TestFS(t, func(t *testing.T) (filesys.FS, filesys.URI) {
+		fs := filesys.NewMemFS(0)
+		root := filesys.NewURI(filesys.MemScheme, "/root")
+		fstest.MkDir(t, fs, root)
+		return fs, root
+	})
+}
+
+// TestGCPBucketFSConformance runs against the bucket path set in FILEB_TEST_GS_URI, eg. gs://bucket/tests
+func TestGCPBucketFSConformance(t *testing.T) {
+	base := os.Getenv("FILEB_TEST_GS_URI")
+	if base == "" {
+		t.Skip("FILEB_TEST_GS_URI not set")
+	}
+	fstest.TestFS(t, func(t *testing.T) (filesys.FS, filesys.URI) {
+		ctx := context.Background()
+		root, err := filesys.ParseURI(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root = root.Join(t.Name())
+		fs := filesys.NewGCPBucketFS()
+		if err := fs.Connect(ctx); err != nil {
+			t.Fatal(err)
+		}
+		fstest.MkDir(t, fs, root)
+		t.Cleanup(func() {
+			fs.Delete(ctx, root, true)
+			fs.Disconnect()
+		})
+		return fs, root
+	})
+}
@@ -0,0 +1,120 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"syscall"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+// Backend independent error kinds, every FS maps its native errors to them
+var (
+	ErrPermission   = errors.New("permission denied")
+	ErrPrecondition = errors.New("precondition failed")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrNoSpace      = errors.New("no space left or storage quota exceeded")
+	ErrTransient    = errors.New("transient error")
+)
+
+/*
+PathError records a failed operation on a URI.
+
+Err wraps both the error kind (ErrNotFound, ErrPermission...) and the
+native backend error, so both can be checked with errors.Is.
+*/
+type PathError struct {
+	Op  string
+	URI URI
+	Err error
+}
+
+func (e *PathError) Error() string { return e.Op + " " + e.URI.String() + ": " + e.Err.Error() }
+func (e *PathError) Unwrap() error { return e.Err }
+
+// kindError is a native error tagged with its error kind
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.kind.Error() + " : " + e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// newPathError returns err as a *PathError, mapping it with kind, nil if err is nil
+func newPathError(op string, uri URI, err error, kind func(error) error) error {
+	if err == nil {
+		return nil
+	}
+	var pathErr *PathError
+	if errors.As(err, &pathErr) {
+		return err
+	}
+	if k := kind(err); k != nil && !errors.Is(err, k) {
+		err = &kindError{kind: k, err: err}
+	}
+	return &PathError{Op: op, URI: uri, Err: err}
+}
+
+// localErrorKind maps os errors to error kinds
+func localErrorKind(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return ErrPermission
+	case errors.Is(err, fs.ErrExist):
+		return ErrAlreadyExists
+	case errors.Is(err, syscall.ENOTEMPTY):
+		return ErrDirNotEmpty
+	case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EINTR), errors.Is(err, syscall.EBUSY):
+		return ErrTransient
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		return ErrNoSpace
+	}
+	return nil
+}
+
+// gcpErrorKind maps storage and googleapi errors to error kinds
+func gcpErrorKind(err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) || errors.Is(err, storage.ErrBucketNotExist) {
+		return ErrNotFound
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.Code; {
+		case code == http.StatusUnauthorized, code == http.StatusForbidden:
+			return ErrPermission
+		case code == http.StatusNotFound:
+			return ErrNotFound
+		case code == http.StatusConflict:
+			return ErrAlreadyExists
+		case code == http.StatusPreconditionFailed:
+			return ErrPrecondition
+		case code == http.StatusTooManyRequests:
+			return ErrRateLimited
+		case code == http.StatusRequestTimeout, code >= 500:
+			return ErrTransient
+		}
+		return nil
+	}
+	return networkErrorKind(err)
+}
+
+// networkErrorKind reports connection failures as transient errors
+func networkErrorKind(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrTransient
+	}
+	return nil
+}
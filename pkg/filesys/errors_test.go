@@ -0,0 +1,90 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+)
+
+func TestGCPErrorKind(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "object not exist", err: storage.ErrObjectNotExist, want: ErrNotFound},
+		{name: "bucket not exist", err: storage.ErrBucketNotExist, want: ErrNotFound},
+		{name: "forbidden", err: &googleapi.Error{Code: http.StatusForbidden}, want: ErrPermission},
+		{name: "conflict", err: &googleapi.Error{Code: http.StatusConflict}, want: ErrAlreadyExists},
+		{name: "precondition", err: &googleapi.Error{Code: http.StatusPreconditionFailed}, want: ErrPrecondition},
+		{name: "rate limited", err: &googleapi.Error{Code: http.StatusTooManyRequests}, want: ErrRateLimited},
+		{name: "unavailable", err: fmt.Errorf("upload: %w", &googleapi.Error{Code: http.StatusServiceUnavailable}), want: ErrTransient},
+		{name: "connection reset", err: syscall.ECONNRESET, want: ErrTransient},
+		{name: "canceled", err: context.Canceled, want: nil},
+		{name: "bad request", err: &googleapi.Error{Code: http.StatusBadRequest}, want: nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := gcpError("open", NewURI(GCPBucketScheme, "bucket/object"), tc.err)
+			var pathErr *PathError
+			assert.ErrorAs(t, err, &pathErr)
+			assert.Equal(t, "open", pathErr.Op)
+			assert.ErrorIs(t, err, tc.err)
+			if tc.want != nil {
+				assert.ErrorIs(t, err, tc.want)
+			}
+		})
+	}
+	assert.NoError(t, gcpError("open", NewURI(GCPBucketScheme, "bucket/object"), nil))
+}
+
+func TestLocalErrors(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	missing := NewURI(LocalScheme, filepath.Join(dir, "missing"))
+
+	_, err := filesys.Reader(ctx, missing)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	var pathErr *PathError
+	assert.ErrorAs(t, err, &pathErr)
+	assert.Equal(t, "open", pathErr.Op)
+	assert.Equal(t, missing, pathErr.URI)
+
+	err = filesys.Delete(ctx, missing, false)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Stat errors other than not found are reported instead of crashing
+	notDir := filepath.Join(dir, "file")
+	assert.NoError(t, os.WriteFile(notDir, nil, 0644))
+	err = filesys.Delete(ctx, NewURI(LocalScheme, filepath.Join(notDir, "child")), false)
+	assert.Error(t, err)
+	assert.True(t, errors.As(err, &pathErr))
+
+	_, err = filesys.MkDir(ctx, NewURI(LocalScheme, dir))
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	if os.Geteuid() != 0 {
+		locked := filepath.Join(dir, "locked")
+		assert.NoError(t, os.WriteFile(locked, nil, 0))
+		_, err = filesys.Reader(ctx, NewURI(LocalScheme, locked))
+		assert.ErrorIs(t, err, ErrPermission)
+	}
+
+	// A full disk is not retried, unlike a rate limit
+	for _, errno := range []syscall.Errno{syscall.ENOSPC, syscall.EDQUOT} {
+		err = localError("write", missing, errno)
+		assert.ErrorIs(t, err, ErrNoSpace)
+		assert.False(t, DefaultRetryPolicy.IsRetryable(err))
+	}
+}
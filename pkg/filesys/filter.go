@@ -0,0 +1,253 @@
+package filesys
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// IgnoreFileName is the name of the gitignore style files read by a Filter with IgnoreFile set to it
+const IgnoreFileName = ".filebignore"
+
+/*
+Filter selects the nodes of recursive operations: Walk, Copy, Move,
+Delete and Sync only handle the files and directories it keeps.
+
+Patterns use the gitignore syntax on top of Match: a pattern without "/"
+matches a name at any depth, otherwise it matches the path relative to the
+walked root, or to the directory of its ignore file. A trailing "/" only
+matches directories and a leading "!" keeps what a previous pattern excluded.
+An excluded directory is skipped with all its content.
+
+A nil *Filter keeps everything.
+*/
+type Filter struct {
+	// Include keeps only the files matching one of the patterns, directories are always walked
+	Include []string
+	// Exclude skips the files and directories matching the patterns, the last matching one decides
+	Exclude []string
+	// IgnoreFile is the name of the files with Exclude patterns read in every directory, eg. IgnoreFileName
+	IgnoreFile string
+	// MinSize skips the files smaller than MinSize bytes
+	MinSize int64
+	// MaxAge skips the files modified more than MaxAge ago, 0 keeps every file
+	MaxAge time.Duration
+}
+
+/*
+ReadPatterns reads one pattern per line, like an ignore file
+or a --exclude-from file. Blank lines and "#" comments are skipped.
+*/
+func ReadPatterns(r io.Reader) ([]string, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+/*
+Walk walks root on fs like FS.Walk, calling fn only for the nodes f keeps.
+An excluded directory is not walked. A file root is always kept.
+
+returns ErrBadPattern if a pattern of f or of an ignore file is malformed
+*/
+func (f *Filter) Walk(ctx context.Context, fs FS, root URI, opts WalkOptions, fn WalkFunc) error {
+	opts.Filter = nil
+	if f == nil {
+		return fs.Walk(ctx, root, opts, fn)
+	}
+	m, err := f.matcher(fs, root)
+	if err != nil {
+		return err
+	}
+	return fs.Walk(ctx, root, opts, func(node Node) error {
+		keep, err := m.keep(ctx, node)
+		switch {
+		case err != nil:
+			return err
+		case keep:
+			return fn(node)
+		case node.IsDir:
+			return SkipDir
+		}
+		return nil
+	})
+}
+
+// filterRule is a parsed pattern, matching paths under the directory base
+type filterRule struct {
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+func parseFilterRule(base, pattern string) (filterRule, error) {
+	rule := filterRule{base: base}
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate, pattern = true, pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly, pattern = true, strings.TrimRight(pattern, "/")
+	}
+	if strings.Contains(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+	} else {
+		pattern = "**/" + pattern
+	}
+	segments, err := globSegments(pattern)
+	if err != nil {
+		return rule, err
+	}
+	rule.segments = segments
+	return rule, nil
+}
+
+func parseFilterRules(base string, patterns []string) ([]filterRule, error) {
+	rules := make([]filterRule, 0, len(patterns))
+	for _, pattern := range patterns {
+		rule, err := parseFilterRule(base, pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// match reports whether the path rel, relative to the walked root, matches the rule
+func (r filterRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	match, _ := matchSegments(r.segments, strings.Split(rel, "/"))
+	return match
+}
+
+// filterMatcher applies a Filter to the nodes of a walk, loading the ignore files as directories are reached
+type filterMatcher struct {
+	filter  *Filter
+	fs      FS
+	root    URI
+	include []filterRule
+	exclude []filterRule
+	// ignores holds the rules of the ignore file of each directory, by path relative to root
+	ignores map[string][]filterRule
+	since   time.Time
+}
+
+func (f *Filter) matcher(fs FS, root URI) (*filterMatcher, error) {
+	m := &filterMatcher{filter: f, fs: fs, root: root, ignores: map[string][]filterRule{}}
+	var err error
+	if m.include, err = parseFilterRules("", f.Include); err != nil {
+		return nil, &PathError{Op: "filter", URI: root, Err: err}
+	}
+	if m.exclude, err = parseFilterRules("", f.Exclude); err != nil {
+		return nil, &PathError{Op: "filter", URI: root, Err: err}
+	}
+	if f.MaxAge > 0 {
+		m.since = time.Now().Add(-f.MaxAge)
+	}
+	return m, nil
+}
+
+// keep reports whether node passes the filter
+func (m *filterMatcher) keep(ctx context.Context, node Node) (bool, error) {
+	rel, err := m.root.Rel(node.URI)
+	if err != nil || rel == "." {
+		return true, nil
+	}
+	excluded := false
+	for _, rule := range m.exclude {
+		if rule.match(rel, node.IsDir) {
+			excluded = !rule.negate
+		}
+	}
+	// Ignore files from the root down, the deepest one has the last word
+	var dirs []string
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, "")
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rules, err := m.ignoreRules(ctx, dirs[i])
+		if err != nil {
+			return false, err
+		}
+		for _, rule := range rules {
+			if rule.match(rel, node.IsDir) {
+				excluded = !rule.negate
+			}
+		}
+	}
+	if excluded {
+		return false, nil
+	}
+	if node.IsDir {
+		return true, nil
+	}
+	if len(m.include) > 0 {
+		included := false
+		for _, rule := range m.include {
+			if rule.match(rel, false) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false, nil
+		}
+	}
+	if node.Size < m.filter.MinSize {
+		return false, nil
+	}
+	if !m.since.IsZero() && !node.ModTime.IsZero() && node.ModTime.Before(m.since) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// ignoreRules returns the rules of the ignore file of dir, reading it the first time
+func (m *filterMatcher) ignoreRules(ctx context.Context, dir string) ([]filterRule, error) {
+	if m.filter.IgnoreFile == "" {
+		return nil, nil
+	}
+	if rules, ok := m.ignores[dir]; ok {
+		return rules, nil
+	}
+	uri := m.root.Join(dir, m.filter.IgnoreFile)
+	r, err := m.fs.Reader(ctx, uri)
+	if errors.Is(err, ErrNotFound) {
+		m.ignores[dir] = nil
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	patterns, err := ReadPatterns(r)
+	if err != nil {
+		return nil, &PathError{Op: "filter", URI: uri, Err: err}
+	}
+	rules, err := parseFilterRules(dir, patterns)
+	if err != nil {
+		return nil, &PathError{Op: "filter", URI: uri, Err: err}
+	}
+	m.ignores[dir] = rules
+	return rules, nil
+}
@@ -0,0 +1,166 @@
+package filesys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// filterTree writes a MemFS tree with an ignore file in /src/logs
+func filterTree(t *testing.T, m *MemFS) {
+	ctx := context.Background()
+	for _, dir := range []string{"/src/logs/old", "/src/build", "/src/img"} {
+		_, err := m.MkDir(ctx, memURI(dir))
+		assert.NoError(t, err)
+	}
+	writeMemFile(t, m, "/src/a.txt", "a")
+	writeMemFile(t, m, "/src/big.txt", "0123456789")
+	writeMemFile(t, m, "/src/build/out.bin", "bin")
+	writeMemFile(t, m, "/src/img/a.jpg", "jpg")
+	writeMemFile(t, m, "/src/img/b.png", "png")
+	writeMemFile(t, m, "/src/logs/x.log", "log")
+	writeMemFile(t, m, "/src/logs/keep.log", "log")
+	writeMemFile(t, m, "/src/logs/old/y.log", "log")
+	writeMemFile(t, m, "/src/logs/"+IgnoreFileName, "# logs\n*.log\n!keep.log\n/old/\n")
+}
+
+func filterWalk(t *testing.T, fs FS, root URI, f *Filter) []string {
+	var rels []string
+	err := f.Walk(context.Background(), fs, root, WalkOptions{Recursive: true}, func(node Node) error {
+		rel, err := root.Rel(node.URI)
+		rels = append(rels, rel)
+		return err
+	})
+	assert.NoError(t, err)
+	return rels
+}
+
+func TestFilter(t *testing.T) {
+	m := NewMemFS(0)
+	filterTree(t, m)
+	src := memURI("/src")
+
+	all := []string{"a.txt", "big.txt", "build", "build/out.bin", "img", "img/a.jpg", "img/b.png",
+		"logs", "logs/" + IgnoreFileName, "logs/keep.log", "logs/old", "logs/old/y.log", "logs/x.log"}
+	var nilFilter *Filter
+	assert.Equal(t, all, filterWalk(t, m, src, nilFilter))
+	assert.Equal(t, all, filterWalk(t, m, src, &Filter{}))
+
+	testCases := []struct {
+		name   string
+		filter Filter
+		rels   []string
+	}{
+		{
+			name:   "exclude",
+			filter: Filter{Exclude: []string{"build/", "*.png", "logs"}},
+			rels:   []string{"a.txt", "big.txt", "img", "img/a.jpg"},
+		},
+		{
+			name:   "anchored and negated",
+			filter: Filter{Exclude: []string{"/img/*", "!a.jpg", "logs/**/*.log"}},
+			rels:   []string{"a.txt", "big.txt", "build", "build/out.bin", "img", "img/a.jpg", "logs", "logs/" + IgnoreFileName, "logs/old"},
+		},
+		{
+			name:   "include",
+			filter: Filter{Include: []string{"*.jpg", "build/*"}},
+			rels:   []string{"build", "build/out.bin", "img", "img/a.jpg", "logs", "logs/old"},
+		},
+		{
+			name:   "ignore file",
+			filter: Filter{IgnoreFile: IgnoreFileName, Exclude: []string{"a.*"}},
+			rels:   []string{"big.txt", "build", "build/out.bin", "img", "img/b.png", "logs", "logs/" + IgnoreFileName, "logs/keep.log"},
+		},
+		{
+			name:   "min size",
+			filter: Filter{MinSize: 4},
+			rels:   []string{"big.txt", "build", "img", "logs", "logs/" + IgnoreFileName, "logs/old"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.rels, filterWalk(t, m, src, &tc.filter))
+		})
+	}
+
+	err := (&Filter{Exclude: []string{"[a"}}).Walk(context.Background(), m, src, WalkOptions{}, func(Node) error { return nil })
+	assert.ErrorIs(t, err, ErrBadPattern)
+}
+
+func TestFilterMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("old"), 0644))
+	old := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "old.txt"), old, old))
+
+	rels := filterWalk(t, NewLocalFS(), NewURI(LocalScheme, dir), &Filter{MaxAge: 24 * time.Hour})
+	assert.Equal(t, []string{"new.txt"}, rels)
+}
+
+func TestReadPatterns(t *testing.T) {
+	patterns, err := ReadPatterns(strings.NewReader("# comment\n*.log\n\n  build/  \n!keep.log\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"*.log", "build/", "!keep.log"}, patterns)
+}
+
+func TestFilteredOperations(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+	fs, err := client.FS(ctx, MemScheme)
+	assert.NoError(t, err)
+	m := fs.(*MemFS)
+	filterTree(t, m)
+	f := &Filter{Exclude: []string{"*.log", "build/"}}
+
+	// Copy on the same file system does not use the native copy
+	assert.NoError(t, client.Copy(ctx, memURI("/src"), memURI("/copy"), true, WithFilter(f)))
+	assert.Equal(t, []string{"a.txt", "big.txt", "img", "img/a.jpg", "img/b.png", "logs", "logs/" + IgnoreFileName, "logs/old"},
+		filterWalk(t, m, memURI("/copy"), nil))
+
+	// Cross file system copy, and its plan
+	dst := NewURI(LocalScheme, filepath.Join(t.TempDir(), "dst"))
+	plan, err := client.PlanCopy(ctx, memURI("/src"), dst, true, WithFilter(f))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, plan.Creates)
+	assert.NoError(t, client.Copy(ctx, memURI("/src"), dst, true, WithFilter(f)))
+	assert.Equal(t, []string{"a.txt", "big.txt", "img", "img/a.jpg", "img/b.png", "logs", "logs/" + IgnoreFileName, "logs/old"},
+		filterWalk(t, NewLocalFS(), dst, nil))
+
+	// Sync never deletes excluded destination files
+	assert.NoError(t, os.WriteFile(filepath.Join(dst.Path, "extra.log"), []byte("x"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dst.Path, "extra.txt"), []byte("x"), 0644))
+	summary, err := client.Sync(ctx, memURI("/src"), dst, WithDelete(), WithCopyOptions(WithFilter(f)))
+	assert.NoError(t, err)
+	assert.Equal(t, []SyncAction{{Op: SyncDelete, Dst: dst.Join("extra.txt"), Size: 1}}, summary.Actions)
+	_, err = os.Stat(filepath.Join(dst.Path, "extra.log"))
+	assert.NoError(t, err)
+
+	// Nor the directories holding them
+	assert.NoError(t, os.MkdirAll(filepath.Join(dst.Path, "gone", "sub"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dst.Path, "gone", "a.txt"), []byte("x"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dst.Path, "gone", "sub", "b.log"), []byte("x"), 0644))
+	summary, err = client.Sync(ctx, memURI("/src"), dst, WithDelete(), WithCopyOptions(WithFilter(f)))
+	assert.NoError(t, err)
+	assert.Equal(t, []SyncAction{{Op: SyncDelete, Dst: dst.Join("gone", "a.txt"), Size: 1}}, summary.Actions)
+	assert.Equal(t, []string{"sub", "sub/b.log"}, filterWalk(t, NewLocalFS(), dst.Join("gone"), nil))
+
+	// Delete only removes the kept files
+	plan, err = client.PlanDelete(ctx, memURI("/copy"), true, WithDeleteFilter(&Filter{Include: []string{"*.jpg", "*.png"}}))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, plan.Deletes)
+	assert.NoError(t, client.Delete(ctx, memURI("/copy"), true, WithDeleteFilter(&Filter{Include: []string{"*.jpg", "*.png"}})))
+	assert.Equal(t, []string{"a.txt", "big.txt", "img", "logs", "logs/" + IgnoreFileName, "logs/old"},
+		filterWalk(t, m, memURI("/copy"), nil))
+
+	// Move keeps the excluded files in the source
+	assert.NoError(t, client.Move(ctx, memURI("/src/logs"), memURI("/moved"), true, WithFilter(&Filter{IgnoreFile: IgnoreFileName})))
+	assert.Equal(t, []string{IgnoreFileName, "keep.log"}, filterWalk(t, m, memURI("/moved"), nil))
+	assert.Equal(t, []string{"old", "old/y.log", "x.log"}, filterWalk(t, m, memURI("/src/logs"), nil))
+}
@@ -1,10 +1,11 @@
 package filesys
 
 import (
+	"context"
 	"errors"
 	"io"
-	"path"
-	"regexp"
+	"io/fs"
+	"time"
 )
 
 var (
@@ -16,46 +17,95 @@ var (
 	ErrNotFound      = errors.New("file not found")
 	ErrAlreadyExists = errors.New("already exists")
 	ErrDirNotEmpty   = errors.New("directory not empty")
+	ErrIsDir         = errors.New("is a directory")
+	ErrNotDir        = errors.New("not a directory")
 
 	ErrConnecting    = errors.New("failed to connect filesystem")
 	ErrDisconnecting = errors.New("failed to disconnect filesystem")
 )
 
-// FS is an abstraction for file system operations.
+/*
+FS is an abstraction for file system operations.
+
+Every operation takes a context.Context, implementations must stop working
+and return ctx.Err() as soon as possible once the context is done.
+Streams returned by Writer and Reader are bound to the context they were
+created with.
+*/
 type FS interface {
 	/*
 		Connect connects to the file system.
 		It should be called before any other method since some file systems
 		may require an initialization.
 	*/
-	Connect() error
+	Connect(ctx context.Context) error
 	// Disconnect closes the connection from the file system.
 	Disconnect() error
 
-	// Create creates a file.
-	Writer(fileName URI) (io.WriteCloser, error)
+	// Create creates a file, opts set its metadata on the file systems that keep it.
+	Writer(ctx context.Context, fileName URI, opts ...WriterOption) (io.WriteCloser, error)
 	// Open opens a file.
-	Reader(fileName URI) (io.ReadCloser, error)
+	Reader(ctx context.Context, fileName URI) (io.ReadCloser, error)
 
 	// Delete deletes a file or directory.
-	Delete(path URI, recursive bool) error
+	Delete(ctx context.Context, path URI, recursive bool) error
 	// Copy copies a file or directory on the same file system.
-	Copy(old, new URI, recursive bool) error
+	Copy(ctx context.Context, old, new URI, recursive bool) error
 	// List lists files in a path.
-	List(path URI, recursive bool) ([]Node, error)
+	List(ctx context.Context, path URI, recursive bool) ([]Node, error)
+	// Walk calls fn for every node under root as they are listed, without the root itself.
+	Walk(ctx context.Context, root URI, opts WalkOptions, fn WalkFunc) error
 	// Get gets a file or directory.
-	Get(path URI) (Node, error)
+	Get(ctx context.Context, path URI) (Node, error)
 	// MkDir creates a directory
-	MkDir(path URI) (Node, error)
+	MkDir(ctx context.Context, path URI) (Node, error)
+}
+
+/*
+Mover is implemented by file systems that move files natively, faster than
+a copy followed by a delete. The manager Move uses it within one file system.
+*/
+type Mover interface {
+	/*
+		Move moves the file or directory src with all its content to dst,
+		which must not exist while its parent must.
+
+		returns:
+		  - ErrNotFound if src or the parent of dst does not exist
+		  - ErrAlreadyExists if dst exists
+	*/
+	Move(ctx context.Context, src, dst URI) error
 }
 
+/*
+Node is a file or directory of a file system with its metadata.
+
+Metadata fields are filled in on a best effort basis, fields a backend
+does not support are left to their zero value.
+*/
 type Node struct {
 	URI   URI
 	IsDir bool
-	// Filesystem??
-	// Size??
-	// LastModified??
-
+	// Size of the file in bytes, 0 for directories
+	Size int64
+	// ModTime is the last modification time
+	ModTime time.Time
+	// ContentType is the MIME type of the file, if known
+	ContentType string
+	// Mode holds the POSIX permission bits and type of the file
+	Mode fs.FileMode
+	// Checksums of the file content as reported by the backend, keyed by algorithm
+	Checksums map[HashAlgorithm][]byte
+	// Generation is the version of the object, for backends with object versioning
+	Generation int64
+	// ETag is the HTTP entity tag of the object, for backends that have one
+	ETag string
+	// Owner owns the file, nil if unknown
+	Owner *Owner
+	// IsLink is set for a symbolic link, whose other fields depend on the SymlinkPolicy
+	IsLink bool
+	// LinkTarget is the path a symbolic link points to
+	LinkTarget string
 }
 
 func NewNode(uri URI, isDir bool) Node {
@@ -64,71 +114,3 @@ func NewNode(uri URI, isDir bool) Node {
 		IsDir: isDir,
 	}
 }
-
-/*
-URI is a resource identifier. It is composed of a scheme and a path, separated by ":/"
-such : [scheme]:/[path]
-*/
-type URI struct {
-	// Scheme of the resource
-	Scheme string
-	// Path of the resource in the scheme
-	Path string
-	// Name of the resource
-	Name string
-}
-
-func (u URI) String() string {
-	return u.Scheme + "://" + u.Path
-}
-
-// NewURI creates a new URI from a scheme and a path and sets the name to the base of the path
-func NewURI(s string, p string) URI {
-	return URI{Scheme: s, Path: p, Name: path.Base(p)}
-}
-
-var ErrInvalidURI = errors.New("invalid URI")
-
-var re = regexp.MustCompile(`^(?:(\w+):\/\/)?(.+)$`)
-
-func ParseURI(uri string) (URI, error) {
-	// It looks for an optional scheme followed by '://', and then captures the rest of the string
-	matches := re.FindStringSubmatch(uri)
-	if matches == nil || len(matches) != 3 {
-		return NewURI("", uri), ErrInvalidURI
-	}
-	scheme, path := matches[1], matches[2]
-	if scheme != "" && !ValidScheme(scheme) {
-		return NewURI(scheme, path), ErrUnknownScheme
-	}
-	return NewURI(scheme, path), nil
-}
-
-const (
-	LocalScheme     string = ""
-	GCPBucketScheme string = "gs"
-)
-
-func ValidScheme(scheme string) bool {
-	switch scheme {
-	case LocalScheme, GCPBucketScheme:
-		return true
-	default:
-		return false
-	}
-}
-
-var schemes = map[string]FS{
-	GCPBucketScheme: NewGCPBucketFS(),
-	LocalScheme:     NewLocalFS(),
-}
-
-func SchemeFS(scheme string) FS {
-	fs, ok := schemes[scheme]
-	if !ok {
-		return nil
-	}
-	return fs
-}
-
-var ErrUnknownScheme = errors.New("unknown scheme")
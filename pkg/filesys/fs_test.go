@@ -1,6 +1,7 @@
 package filesys
 
 import (
+	"context"
 	"errors"
 	"testing"
 )
@@ -62,3 +63,53 @@ func TestCheckPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestRegisterScheme(t *testing.T) {
+	factory := func(Options) FS { return NoopFS{} }
+
+	if err := RegisterScheme("noop-test", factory); err != nil {
+		t.Fatalf("RegisterScheme() = %v, want nil", err)
+	}
+	uri, err := ParseURI("noop-test://bucket/file.txt")
+	if err != nil {
+		t.Fatalf("ParseURI() = %v, want nil", err)
+	}
+	if uri.Scheme != "noop-test" || uri.Path != "bucket/file.txt" {
+		t.Errorf("ParseURI() = %v, want noop-test://bucket/file.txt", uri)
+	}
+	if _, err := SchemeFS("noop-test"); err != nil {
+		t.Errorf("SchemeFS() = %v, want nil", err)
+	}
+	if _, err := List(context.Background(), uri, true); err != nil {
+		t.Errorf("List() = %v, want nil", err)
+	}
+
+	testCases := []struct {
+		name    string
+		scheme  string
+		factory SchemeFactory
+		wantErr error
+	}{
+		{name: "already registered", scheme: "noop-test", factory: factory, wantErr: ErrSchemeRegistered},
+		{name: "builtin", scheme: GCPBucketScheme, factory: factory, wantErr: ErrSchemeRegistered},
+		{name: "invalid name", scheme: "1noop", factory: factory, wantErr: ErrInvalidScheme},
+		{name: "nil factory", scheme: "noop-nil", factory: nil, wantErr: ErrInvalidScheme},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := RegisterScheme(tc.scheme, tc.factory)
+			var schemeErr *SchemeError
+			if !errors.As(err, &schemeErr) || schemeErr.Scheme != tc.scheme {
+				t.Errorf("RegisterScheme(%v) = %v, want *SchemeError", tc.scheme, err)
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("RegisterScheme(%v) = %v, want %v", tc.scheme, err, tc.wantErr)
+			}
+		})
+	}
+
+	_, err = SchemeFS("unregistered")
+	if !errors.Is(err, ErrUnknownScheme) {
+		t.Errorf("SchemeFS() = %v, want %v", err, ErrUnknownScheme)
+	}
+}
@@ -0,0 +1,490 @@
+/*
+Package fstest implements a behavioral test suite for filesys.FS implementations.
+
+Any backend passing TestFS behaves like the built-in file systems for
+creating, reading, listing, copying and deleting files:
+
+	func TestMyFS(t *testing.T) {
+		fstest.TestFS(t, func(t *testing.T) (filesys.FS, filesys.URI) {
+			fs := NewMyFS()
+			return fs, filesys.NewURI("my", t.Name())
+		})
+	}
+*/
+package fstest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/B87/file-bridge/pkg/filesys"
+)
+
+/*
+Factory returns a connected file system and an existing, empty directory of it.
+It is called once per test of the suite, the suite only writes under the directory.
+*/
+type Factory func(t *testing.T) (filesys.FS, filesys.URI)
+
+// TestFS runs the whole suite against the file systems returned by factory
+func TestFS(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, fs filesys.FS, root filesys.URI)
+	}{
+		{name: "WriteRead", test: testWriteRead},
+		{name: "NotFound", test: testNotFound},
+		{name: "MkDir", test: testMkDir},
+		{name: "List", test: testList},
+		{name: "Walk", test: testWalk},
+		{name: "Delete", test: testDelete},
+		{name: "Copy", test: testCopy},
+		{name: "Move", test: testMove},
+		{name: "Canceled", test: testCanceled},
+		{name: "Concurrent", test: testConcurrent},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, root := factory(t)
+			tc.test(t, fs, root)
+		})
+	}
+}
+
+// WriteFile writes content to the file uri
+func WriteFile(t *testing.T, fs filesys.FS, uri filesys.URI, content string) {
+	t.Helper()
+	w, err := fs.Writer(context.Background(), uri)
+	if err != nil {
+		t.Fatalf("Writer(%s) = %v", uri, err)
+	}
+	if _, err := io.WriteString(w, content); err != nil {
+		w.Close()
+		t.Fatalf("Write(%s) = %v", uri, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close(%s) = %v", uri, err)
+	}
+}
+
+// ReadFile returns the content of the file uri
+func ReadFile(t *testing.T, fs filesys.FS, uri filesys.URI) string {
+	t.Helper()
+	r, err := fs.Reader(context.Background(), uri)
+	if err != nil {
+		t.Fatalf("Reader(%s) = %v", uri, err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Read(%s) = %v", uri, err)
+	}
+	return string(b)
+}
+
+// MkDir creates the directory uri
+func MkDir(t *testing.T, fs filesys.FS, uri filesys.URI) {
+	t.Helper()
+	if _, err := fs.MkDir(context.Background(), uri); err != nil {
+		t.Fatalf("MkDir(%s) = %v", uri, err)
+	}
+}
+
+/*
+newTree creates the tree:
+
+	a.txt
+	d/b.txt
+	d/e/c.txt
+*/
+func newTree(t *testing.T, fs filesys.FS, root filesys.URI) {
+	t.Helper()
+	MkDir(t, fs, root.Join("d", "e"))
+	WriteFile(t, fs, root.Join("a.txt"), "a")
+	WriteFile(t, fs, root.Join("d", "b.txt"), "b")
+	WriteFile(t, fs, root.Join("d", "e", "c.txt"), "c")
+}
+
+// relPaths returns the sorted paths of the nodes relative to root, directories end with "/"
+func relPaths(t *testing.T, root filesys.URI, nodes []filesys.Node) []string {
+	t.Helper()
+	paths := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		rel, err := root.Rel(node.URI)
+		if err != nil {
+			t.Fatalf("node %s is not under %s", node.URI, root)
+		}
+		if node.IsDir {
+			rel = strings.TrimSuffix(rel, "/") + "/"
+		}
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// files returns the paths that are not directories
+func files(paths []string) []string {
+	var files []string
+	for _, p := range paths {
+		if !strings.HasSuffix(p, "/") {
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+func equal(t *testing.T, what string, got, want any) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func errorIs(t *testing.T, what string, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Errorf("%s = %v, want %v", what, err, want)
+	}
+}
+
+func testWriteRead(t *testing.T, fs filesys.FS, root filesys.URI) {
+	ctx := context.Background()
+	uri := root.Join("file.txt")
+	WriteFile(t, fs, uri, "hello world")
+	equal(t, "content", ReadFile(t, fs, uri), "hello world")
+
+	node, err := fs.Get(ctx, uri)
+	if err != nil {
+		t.Fatalf("Get(%s) = %v", uri, err)
+	}
+	equal(t, "IsDir", node.IsDir, false)
+	equal(t, "Size", node.Size, 11)
+	equal(t, "Name", node.URI.Name, "file.txt")
+
+	WriteFile(t, fs, uri, "bye")
+	equal(t, "overwritten content", ReadFile(t, fs, uri), "bye")
+	node, err = fs.Get(ctx, uri)
+	if err != nil {
+		t.Fatalf("Get(%s) = %v", uri, err)
+	}
+	equal(t, "overwritten Size", node.Size, 3)
+
+	empty := root.Join("empty.txt")
+	WriteFile(t, fs, empty, "")
+	equal(t, "empty content", ReadFile(t, fs, empty), "")
+}
+
+func testNotFound(t *testing.T, fs filesys.FS, root filesys.URI) {
+	ctx := context.Background()
+	missing := root.Join("missing", "file.txt")
+
+	_, err := fs.Reader(ctx, missing)
+	errorIs(t, "Reader", err, filesys.ErrNotFound)
+	_, err = fs.Get(ctx, missing)
+	errorIs(t, "Get", err, filesys.ErrNotFound)
+	err = fs.Delete(ctx, missing, true)
+	errorIs(t, "Delete", err, filesys.ErrNotFound)
+	_, err = fs.List(ctx, missing, true)
+	errorIs(t, "List", err, filesys.ErrNotFound)
+	err = fs.Walk(ctx, missing, filesys.WalkOptions{}, func(filesys.Node) error { return nil })
+	errorIs(t, "Walk", err, filesys.ErrNotFound)
+	err = fs.Copy(ctx, missing, root.Join("dst.txt"), true)
+	errorIs(t, "Copy", err, filesys.ErrNotFound)
+
+	var pathErr *filesys.PathError
+	if _, err := fs.Reader(ctx, missing); !errors.As(err, &pathErr) {
+		t.Errorf("Reader error = %T, want *filesys.PathError", err)
+	}
+}
+
+func testMkDir(t *testing.T, fs filesys.FS, root filesys.URI) {
+	ctx := context.Background()
+	nested := root.Join("a", "b", "c")
+	node, err := fs.MkDir(ctx, nested)
+	if err != nil {
+		t.Fatalf("MkDir(%s) = %v", nested, err)
+	}
+	equal(t, "MkDir IsDir", node.IsDir, true)
+	equal(t, "MkDir Name", node.URI.Name, "c")
+
+	for _, uri := range []filesys.URI{root.Join("a"), root.Join("a", "b"), nested} {
+		node, err := fs.Get(ctx, uri)
+		if err != nil {
+			t.Fatalf("Get(%s) = %v", uri, err)
+		}
+		equal(t, "Get IsDir", node.IsDir, true)
+	}
+	_, err = fs.MkDir(ctx, nested)
+	errorIs(t, "MkDir existing", err, filesys.ErrAlreadyExists)
+
+	nodes, err := fs.List(ctx, nested, true)
+	if err != nil {
+		t.Fatalf("List(%s) = %v", nested, err)
+	}
+	equal(t, "List empty dir", len(nodes), 0)
+}
+
+func testList(t *testing.T, fs filesys.FS, root filesys.URI) {
+	ctx := context.Background()
+	newTree(t, fs, root)
+
+	nodes, err := fs.List(ctx, root, false)
+	if err != nil {
+		t.Fatalf("List(%s) = %v", root, err)
+	}
+	equal(t, "List", relPaths(t, root, nodes), []string{"a.txt", "d/"})
+
+	nodes, err = fs.List(ctx, root, true)
+	if err != nil {
+		t.Fatalf("List(%s, recursive) = %v", root, err)
+	}
+	equal(t, "List recursive files", files(relPaths(t, root, nodes)), []string{"a.txt", "d/b.txt", "d/e/c.txt"})
+
+	file := root.Join("d", "b.txt")
+	nodes, err = fs.List(ctx, file, false)
+	if err != nil {
+		t.Fatalf("List(%s) = %v", file, err)
+	}
+	equal(t, "List file", len(nodes), 1)
+	if len(nodes) == 1 {
+		equal(t, "List file name", nodes[0].URI.Name, "b.txt")
+		equal(t, "List file size", nodes[0].Size, 1)
+	}
+}
+
+func testWalk(t *testing.T, fs filesys.FS, root filesys.URI) {
+	ctx := context.Background()
+	newTree(t, fs, root)
+
+	var nodes []filesys.Node
+	err := fs.Walk(ctx, root, filesys.WalkOptions{Recursive: true}, func(node filesys.Node) error {
+		nodes = append(nodes, node)
+		if node.IsDir && node.URI.Name == "e" {
+			return filesys.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk(%s) = %v", root, err)
+	}
+	equal(t, "Walk SkipDir files", files(relPaths(t, root, nodes)), []string{"a.txt", "d/b.txt"})
+
+	count := 0
+	err = fs.Walk(ctx, root, filesys.WalkOptions{Recursive: true}, func(node filesys.Node) error {
+		count++
+		return filesys.SkipAll
+	})
+	if err != nil {
+		t.Fatalf("Walk(%s) SkipAll = %v", root, err)
+	}
+	equal(t, "Walk SkipAll count", count, 1)
+
+	errStop := errors.New("stop")
+	err = fs.Walk(ctx, root, filesys.WalkOptions{}, func(node filesys.Node) error { return errStop })
+	errorIs(t, "Walk error", err, errStop)
+}
+
+func testDelete(t *testing.T, fs filesys.FS, root filesys.URI) {
+	ctx := context.Background()
+	newTree(t, fs, root)
+
+	err := fs.Delete(ctx, root.Join("d"), false)
+	errorIs(t, "Delete non empty dir", err, filesys.ErrDirNotEmpty)
+	_, err = fs.Get(ctx, root.Join("d", "b.txt"))
+	if err != nil {
+		t.Errorf("non recursive Delete removed content: %v", err)
+	}
+
+	if err := fs.Delete(ctx, root.Join("a.txt"), false); err != nil {
+		t.Fatalf("Delete file = %v", err)
+	}
+	_, err = fs.Get(ctx, root.Join("a.txt"))
+	errorIs(t, "Get deleted file", err, filesys.ErrNotFound)
+
+	if err := fs.Delete(ctx, root.Join("d"), true); err != nil {
+		t.Fatalf("Delete recursive = %v", err)
+	}
+	for _, p := range []string{"d", "d/b.txt", "d/e/c.txt"} {
+		_, err = fs.Get(ctx, root.Join(p))
+		errorIs(t, "Get deleted "+p, err, filesys.ErrNotFound)
+	}
+	nodes, err := fs.List(ctx, root, true)
+	if err != nil {
+		t.Fatalf("List(%s) = %v", root, err)
+	}
+	equal(t, "List after Delete", len(nodes), 0)
+}
+
+func testCopy(t *testing.T, fs filesys.FS, root filesys.URI) {
+	ctx := context.Background()
+	newTree(t, fs, root)
+	MkDir(t, fs, root.Join("dst"))
+
+	// File to a new name
+	if err := fs.Copy(ctx, root.Join("a.txt"), root.Join("copy.txt"), false); err != nil {
+		t.Fatalf("Copy file = %v", err)
+	}
+	equal(t, "copied file", ReadFile(t, fs, root.Join("copy.txt")), "a")
+	equal(t, "source file", ReadFile(t, fs, root.Join("a.txt")), "a")
+
+	// File into a directory keeps its name
+	if err := fs.Copy(ctx, root.Join("a.txt"), root.Join("dst"), false); err != nil {
+		t.Fatalf("Copy file to dir = %v", err)
+	}
+	equal(t, "copied file in dir", ReadFile(t, fs, root.Join("dst", "a.txt")), "a")
+
+	// File onto an existing file overwrites it
+	if err := fs.Copy(ctx, root.Join("d", "b.txt"), root.Join("copy.txt"), false); err != nil {
+		t.Fatalf("Copy file to existing file = %v", err)
+	}
+	equal(t, "overwritten file", ReadFile(t, fs, root.Join("copy.txt")), "b")
+	err := fs.Copy(ctx, root.Join("a.txt"), root.Join("a.txt"), false)
+	errorIs(t, "Copy file onto itself", err, filesys.ErrInvalidURI)
+
+	// Directory content to a new directory
+	if err := fs.Copy(ctx, root.Join("d"), root.Join("d2"), true); err != nil {
+		t.Fatalf("Copy dir = %v", err)
+	}
+	nodes, err := fs.List(ctx, root.Join("d2"), true)
+	if err != nil {
+		t.Fatalf("List copied dir = %v", err)
+	}
+	equal(t, "copied dir files", files(relPaths(t, root.Join("d2"), nodes)), []string{"b.txt", "e/c.txt"})
+	equal(t, "copied nested file", ReadFile(t, fs, root.Join("d2", "e", "c.txt")), "c")
+
+	// Without recursive the sub directories are created empty
+	if err := fs.Copy(ctx, root.Join("d"), root.Join("d3"), false); err != nil {
+		t.Fatalf("Copy dir not recursive = %v", err)
+	}
+	nodes, err = fs.List(ctx, root.Join("d3"), true)
+	if err != nil {
+		t.Fatalf("List not recursive copy = %v", err)
+	}
+	equal(t, "not recursive copy", relPaths(t, root.Join("d3"), nodes), []string{"b.txt", "e/"})
+
+	// Copies are independent
+	WriteFile(t, fs, root.Join("d2", "b.txt"), "changed")
+	equal(t, "source after copy change", ReadFile(t, fs, root.Join("d", "b.txt")), "b")
+}
+
+// testMove checks the Mover implementation of fs, it is skipped if there is none
+func testMove(t *testing.T, fs filesys.FS, root filesys.URI) {
+	mover, ok := fs.(filesys.Mover)
+	if !ok {
+		t.Skip("not a filesys.Mover")
+	}
+	ctx := context.Background()
+	newTree(t, fs, root)
+
+	// File to a new name
+	if err := mover.Move(ctx, root.Join("a.txt"), root.Join("moved.txt")); err != nil {
+		t.Fatalf("Move file = %v", err)
+	}
+	equal(t, "moved file", ReadFile(t, fs, root.Join("moved.txt")), "a")
+	_, err := fs.Get(ctx, root.Join("a.txt"))
+	errorIs(t, "Get moved file", err, filesys.ErrNotFound)
+
+	// Onto an existing file, or from a missing one
+	WriteFile(t, fs, root.Join("other.txt"), "other")
+	err = mover.Move(ctx, root.Join("moved.txt"), root.Join("other.txt"))
+	errorIs(t, "Move to existing file", err, filesys.ErrAlreadyExists)
+	equal(t, "existing file", ReadFile(t, fs, root.Join("other.txt")), "other")
+	err = mover.Move(ctx, root.Join("missing.txt"), root.Join("new.txt"))
+	errorIs(t, "Move missing file", err, filesys.ErrNotFound)
+
+	// Directory with its content
+	if err := mover.Move(ctx, root.Join("d"), root.Join("d2")); err != nil {
+		t.Fatalf("Move dir = %v", err)
+	}
+	nodes, err := fs.List(ctx, root.Join("d2"), true)
+	if err != nil {
+		t.Fatalf("List moved dir = %v", err)
+	}
+	equal(t, "moved dir files", files(relPaths(t, root.Join("d2"), nodes)), []string{"b.txt", "e/c.txt"})
+	equal(t, "moved nested file", ReadFile(t, fs, root.Join("d2", "e", "c.txt")), "c")
+	_, err = fs.Get(ctx, root.Join("d"))
+	errorIs(t, "Get moved dir", err, filesys.ErrNotFound)
+}
+
+func testCanceled(t *testing.T, fs filesys.FS, root filesys.URI) {
+	newTree(t, fs, root)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := fs.List(ctx, root, true)
+	errorIs(t, "List", err, context.Canceled)
+	_, err = fs.Get(ctx, root.Join("a.txt"))
+	errorIs(t, "Get", err, context.Canceled)
+	err = fs.Delete(ctx, root.Join("a.txt"), false)
+	errorIs(t, "Delete", err, context.Canceled)
+	if _, err := fs.Get(context.Background(), root.Join("a.txt")); err != nil {
+		t.Errorf("canceled Delete removed the file: %v", err)
+	}
+}
+
+func testConcurrent(t *testing.T, fs filesys.FS, root filesys.URI) {
+	ctx := context.Background()
+	const n = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			uri := root.Join(fmt.Sprintf("file-%02d.txt", i))
+			content := strings.Repeat(fmt.Sprint(i), i+1)
+			if err := writeRead(ctx, fs, uri, content); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := fs.List(ctx, root, false); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	nodes, err := fs.List(ctx, root, false)
+	if err != nil {
+		t.Fatalf("List(%s) = %v", root, err)
+	}
+	equal(t, "List count", len(nodes), n)
+}
+
+// writeRead writes content to uri and checks it reads back, without testing.T for goroutines
+func writeRead(ctx context.Context, fs filesys.FS, uri filesys.URI, content string) error {
+	w, err := fs.Writer(ctx, uri)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, content); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	r, err := fs.Reader(ctx, uri)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if string(b) != content {
+		return fmt.Errorf("read %s = %q, want %q", uri, b, content)
+	}
+	return nil
+}
@@ -1,16 +1,21 @@
 package filesys
 
 import (
-	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"io/fs"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 )
 
 type StorageClient interface {
@@ -20,17 +25,35 @@ type StorageClient interface {
 
 // GCPBucketFS is a FileSystem implementation that uses a GCP bucket.
 type GCPBucketFS struct {
-	ctx    context.Context
-	client StorageClient
+	client  StorageClient
+	options []option.ClientOption
+
+	uploadOnce sync.Once
+	upload     gcpUploader
+}
+
+// NewGCPBucketFS creates a GCPBucketFS, options are passed to the storage client on Connect
+func NewGCPBucketFS(options ...option.ClientOption) *GCPBucketFS {
+	return &GCPBucketFS{options: options}
 }
 
-func NewGCPBucketFS() *GCPBucketFS {
-	return &GCPBucketFS{}
+// gcpClientOptions maps client Options to storage client options
+func gcpClientOptions(opts Options) []option.ClientOption {
+	var options []option.ClientOption
+	if opts.CredentialsFile != "" {
+		options = append(options, option.WithCredentialsFile(opts.CredentialsFile))
+	}
+	if opts.Project != "" {
+		options = append(options, option.WithQuotaProject(opts.Project))
+	}
+	if endpoint, ok := opts.Endpoints[GCPBucketScheme]; ok {
+		options = append(options, option.WithEndpoint(endpoint))
+	}
+	return options
 }
 
-func (fs *GCPBucketFS) Connect() error {
-	fs.ctx = context.Background()
-	client, err := storage.NewClient(fs.ctx)
+func (fs *GCPBucketFS) Connect(ctx context.Context) error {
+	client, err := storage.NewClient(ctx, fs.options...)
 	if err != nil {
 		return err
 	}
@@ -41,91 +64,404 @@ func (fs *GCPBucketFS) Disconnect() error {
 	return fs.client.Close()
 }
 
-func (fs *GCPBucketFS) Writer(uri URI) (io.WriteCloser, error) {
-	bucket, object := splitGCPPath(uri.Path)
-	wc := fs.client.Bucket(bucket).Object(object).NewWriter(fs.ctx)
-	return wc, nil
+/*
+Writer returns a writer for the object, the upload is aborted if ctx is done before Close.
+
+The options set the metadata of the new object, the storage_class query
+option its storage class if WithStorageClass is not given. An empty
+content type is detected from the extension, or sniffed by the storage
+client from the first bytes.
+*/
+func (fs *GCPBucketFS) Writer(ctx context.Context, uri URI, opts ...WriterOption) (io.WriteCloser, error) {
+	obj, err := fs.object(uri)
+	if err != nil {
+		return nil, gcpError("create", uri, err)
+	}
+	md := gcpWriterMetadata(uri, newWriterOptions(opts))
+	wc := obj.NewWriter(ctx)
+	wc.ContentType = md.ContentType
+	wc.CacheControl = md.CacheControl
+	wc.ContentEncoding = md.ContentEncoding
+	wc.StorageClass = md.StorageClass
+	wc.Metadata = md.Custom
+	return gcpWriter{Writer: wc, uri: uri}, nil
 }
 
-func (fs *GCPBucketFS) Reader(uri URI) (io.ReadCloser, error) {
-	bucket, object := splitGCPPath(uri.Path)
-	rc, err := fs.client.Bucket(bucket).Object(object).NewReader(fs.ctx)
+// gcpWriterMetadata returns the metadata of an object written to uri with opts
+func gcpWriterMetadata(uri URI, opts WriterOptions) Metadata {
+	md := opts.Metadata
+	if md.StorageClass == "" {
+		md.StorageClass = uri.Query.Get("storage_class")
+	}
+	if md.ContentType == "" {
+		md.ContentType = detectContentType(uri.Path, nil)
+	}
+	return md
+}
+
+// gcpWriter maps the upload errors, most of them are only known on Close
+type gcpWriter struct {
+	*storage.Writer
+	uri URI
+}
+
+func (w gcpWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	return n, gcpError("write", w.uri, err)
+}
+
+func (w gcpWriter) Close() error {
+	return gcpError("write", w.uri, w.Writer.Close())
+}
+
+// gcpReader maps the download errors
+type gcpReader struct {
+	*storage.Reader
+	uri URI
+}
+
+func (r gcpReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		return n, err
+	}
+	return n, gcpError("read", r.uri, err)
+}
+
+// gcpError maps a storage error to a *PathError
+func gcpError(op string, uri URI, err error) error {
+	return newPathError(op, uri, err, gcpErrorKind)
+}
+
+// Reader opens the object, the generation query option reads a specific version of it.
+func (fs *GCPBucketFS) Reader(ctx context.Context, uri URI) (io.ReadCloser, error) {
+	obj, err := fs.object(uri)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file %s: %w", uri.Path, err)
+		return nil, gcpError("open", uri, err)
 	}
-	return rc, nil
+	rc, err := obj.NewReader(ctx)
+	if err != nil {
+		return nil, gcpError("open", uri, err)
+	}
+	return gcpReader{Reader: rc, uri: uri}, nil
 }
 
-func (fs *GCPBucketFS) Delete(uri URI, recursive bool) error {
+/*
+Delete deletes the object uri, or the objects under the uri folder.
+
+Only the objects under uri followed by "/" are part of the folder,
+"dir" never deletes "dir2/file".
+
+returns:
+  - ErrNotFound if no object was found
+  - ErrDirNotEmpty if the folder has objects and recursive is false
+*/
+func (fs *GCPBucketFS) Delete(ctx context.Context, uri URI, recursive bool) error {
 	bucket, object := splitGCPPath(uri.Path)
-	it := fs.client.Bucket(bucket).Objects(fs.ctx, &storage.Query{Prefix: object})
+	object = strings.TrimSuffix(object, "/")
+	if object != "" {
+		err := fs.client.Bucket(bucket).Object(object).Delete(ctx)
+		if err == nil {
+			return nil
+		} else if !errors.Is(err, storage.ErrObjectNotExist) {
+			return gcpError("delete", uri, err)
+		}
+		object += "/"
+	}
+	it := fs.client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: object})
+	var names []string
 	for {
 		attrs, err := it.Next()
 		if err == iterator.Done {
 			break
 		}
 		if err != nil {
-			return err
+			return gcpError("delete", uri, err)
 		}
-		err = fs.client.Bucket(bucket).Object(attrs.Name).Delete(fs.ctx)
+		names = append(names, attrs.Name)
+		// The folder marker object alone is an empty folder
+		if !recursive && attrs.Name != object {
+			return gcpError("delete", uri, ErrDirNotEmpty)
+		}
+	}
+	if len(names) == 0 {
+		return gcpError("delete", uri, ErrNotFound)
+	}
+	for _, name := range names {
+		err := fs.client.Bucket(bucket).Object(name).Delete(ctx)
 		if err != nil {
-			return err
+			return gcpError("delete", NewURI(uri.Scheme, path.Join(bucket, name)), err)
 		}
 	}
 	return nil
 }
 
-// Copy copies a file from one path to another inside the same GS filesystem.
-// Use manager Copy for cross filesystem copy.
-func (fs *GCPBucketFS) Copy(src, dst URI, recursive bool) error {
+/*
+Copy copies objects inside the same GS filesystem with server side rewrites,
+dst is resolved like POSIX cp -r does, see copyTarget. Without recursive
+only the objects directly under a src directory are copied, its sub
+directories are created empty.
+Use manager Copy for cross filesystem copy.
+
+returns:
+  - ErrNotFound if src does not exist
+  - ErrInvalidURI if src would be copied onto or inside itself
+*/
+func (fs *GCPBucketFS) Copy(ctx context.Context, src, dst URI, recursive bool) error {
+	srcNode, err := fs.Get(ctx, src)
+	if err != nil {
+		return gcpError("copy", src, err)
+	}
+	target, err := copyTarget(ctx, fs, srcNode, dst)
+	if err != nil {
+		return gcpError("copy", dst, err)
+	}
+	if !srcNode.IsDir {
+		return fs.copyObject(ctx, src, target)
+	}
+	return fs.Walk(ctx, src, WalkOptions{Recursive: recursive}, func(node Node) error {
+		if node.IsDir && recursive {
+			// the objects under node create its prefix
+			return nil
+		}
+		rel, err := src.Rel(node.URI)
+		if err != nil {
+			return gcpError("copy", node.URI, err)
+		}
+		if node.IsDir {
+			_, err := fs.MkDir(ctx, target.Join(rel))
+			if err != nil && !errors.Is(err, ErrAlreadyExists) {
+				return err
+			}
+			return nil
+		}
+		return fs.copyObject(ctx, node.URI, target.Join(rel))
+	})
+}
+
+// copyObject rewrites the object src to dst
+func (fs *GCPBucketFS) copyObject(ctx context.Context, src, dst URI) error {
 	srcBucket, srcObject := splitGCPPath(src.Path)
 	srcObj := fs.client.Bucket(srcBucket).Object(srcObject)
 	dstBucket, dstObject := splitGCPPath(dst.Path)
 	dstObj := fs.client.Bucket(dstBucket).Object(dstObject)
-	_, err := dstObj.CopierFrom(srcObj).Run(fs.ctx)
+	_, err := dstObj.CopierFrom(srcObj).Run(ctx)
+	return gcpError("copy", src, err)
+}
+
+/*
+Move moves objects inside the same GS filesystem, GCS has no rename:
+every object is rewritten server side then deleted.
+
+returns:
+  - ErrNotFound if src does not exist
+  - ErrAlreadyExists if dst exists
+*/
+func (fs *GCPBucketFS) Move(ctx context.Context, src, dst URI) error {
+	srcNode, err := fs.Get(ctx, src)
+	if err != nil {
+		return gcpError("move", src, err)
+	}
+	if _, err := fs.Get(ctx, dst); err == nil {
+		return gcpError("move", dst, ErrAlreadyExists)
+	} else if !errors.Is(err, ErrNotFound) {
+		return gcpError("move", dst, err)
+	}
+	if !srcNode.IsDir {
+		return fs.moveObject(ctx, src, dst)
+	}
+	err = fs.Walk(ctx, src, WalkOptions{Recursive: true}, func(node Node) error {
+		if node.IsDir {
+			return nil
+		}
+		rel, err := src.Rel(node.URI)
+		if err != nil {
+			return gcpError("move", node.URI, err)
+		}
+		return fs.moveObject(ctx, node.URI, dst.Join(rel))
+	})
 	if err != nil {
 		return err
 	}
+	// What is left are the folder markers
+	if err := fs.Delete(ctx, src, true); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
 	return nil
 }
 
+// moveObject rewrites the object src to dst then deletes src
+func (fs *GCPBucketFS) moveObject(ctx context.Context, src, dst URI) error {
+	if err := fs.copyObject(ctx, src, dst); err != nil {
+		return err
+	}
+	bucket, object := splitGCPPath(src.Path)
+	return gcpError("move", src, fs.client.Bucket(bucket).Object(object).Delete(ctx))
+}
+
 // List lists files and folders in a path.
-func (fs *GCPBucketFS) List(dir URI, recursive bool) ([]Node, error) {
-	var files []Node
-	bucket, object := splitGCPPath(dir.Path)
-	query := storage.Query{Prefix: object}
-	if !recursive {
-		query.Delimiter = "/"
-	}
-	it := fs.client.Bucket(bucket).Objects(fs.ctx, &query)
-	for {
-		attrs, err := it.Next()
-		if err == iterator.Done {
-			break
+func (fs *GCPBucketFS) List(ctx context.Context, dir URI, recursive bool) ([]Node, error) {
+	return listWalk(ctx, fs, dir, recursive)
+}
+
+/*
+Walk streams the objects under root one storage.Query page at a time.
+
+Folders are listed with the "/" delimiter and walked one level at a time,
+their nodes have a path ending with "/". If root is an object, fn is
+called once with that object.
+
+returns:
+  - ErrNotFound if nothing exists under root
+  - ErrFileList if the bucket listing fails
+  - ErrInvalidPageToken if the page token is not a path under root
+*/
+func (fs *GCPBucketFS) Walk(ctx context.Context, root URI, opts WalkOptions, fn WalkFunc) error {
+	bucket, object := splitGCPPath(root.Path)
+	if object != "" && !strings.HasSuffix(object, "/") {
+		attrs, err := fs.client.Bucket(bucket).Object(object).Attrs(ctx)
+		if err == nil {
+			return ignoreSkip(fn(newGCPNode(root, attrs)))
+		} else if !errors.Is(err, storage.ErrObjectNotExist) {
+			return gcpError("walk", root, err)
 		}
+		object += "/"
+	}
+	token, err := gcpPageToken(bucket, object, opts.PageToken)
+	if err != nil {
+		return gcpError("walk", root, err)
+	}
+	w := gcpWalker{fs: fs, scheme: root.Scheme, bucket: bucket, opts: opts, fn: fn}
+	found, err := w.walk(ctx, object, opts.Prefix, token)
+	if err != nil {
+		return ignoreSkip(err)
+	}
+	if !found && object != "" && token == nil && opts.Prefix == "" {
+		return gcpError("walk", root, ErrNotFound)
+	}
+	return nil
+}
+
+// gcpWalker holds the state of a GCPBucketFS Walk
+type gcpWalker struct {
+	fs     *GCPBucketFS
+	scheme string
+	bucket string
+	opts   WalkOptions
+	fn     WalkFunc
+}
+
+/*
+walk lists one folder level, calling fn for each entry and
+descending into sub folders in recursive mode.
+
+Only the entries starting with namePrefix are listed. Token holds the
+remaining page token components, entries up to it are skipped.
+Returns whether anything exists under prefix.
+*/
+func (w gcpWalker) walk(ctx context.Context, prefix, namePrefix string, token []string) (bool, error) {
+	query := &storage.Query{Prefix: prefix + namePrefix, Delimiter: "/"}
+	if len(token) > 0 {
+		query.StartOffset = prefix + token[0]
+	}
+	it := w.fs.client.Bucket(w.bucket).Objects(ctx, query)
+	pager := iterator.NewPager(it, w.opts.PageSize, "")
+	found := false
+	for {
+		var page []*storage.ObjectAttrs
+		next, err := pager.NextPage(&page)
 		if err != nil {
-			log.Fatalf("Failed to iterate: %v", err)
+			uri := NewURI(w.scheme, path.Join(w.bucket, prefix))
+			return found, gcpError("walk", uri, fmt.Errorf("%w : %w", ErrFileList, err))
 		}
-		if attrs.Prefix != "" {
-			// This is a 'folder'
-			files = append(files, NewNode(NewURI(dir.Scheme, attrs.Prefix), true))
-		} else {
-			// This is a file
-			files = append(files, NewNode(NewURI(dir.Scheme, path.Join(attrs.Bucket, attrs.Name)), false))
+		for _, attrs := range page {
+			found = true
+			name := attrs.Name
+			if attrs.Prefix != "" {
+				name = attrs.Prefix
+			}
+			// Skip the placeholder object created by MkDir
+			if name == prefix {
+				continue
+			}
+			isDir := attrs.Prefix != ""
+			key := strings.TrimPrefix(name, prefix)
+			if len(token) > 0 {
+				switch strings.Compare(key, token[0]) {
+				case -1:
+					continue
+				case 0:
+					// Already visited, but its children may not
+					if isDir && w.opts.Recursive {
+						if _, err := w.walk(ctx, name, "", token[1:]); err != nil {
+							return found, err
+						}
+					}
+					token = nil
+					continue
+				}
+				token = nil
+			}
+
+			uri := NewURI(w.scheme, path.Join(w.bucket, name))
+			if !isDir {
+				if err := w.fn(newGCPNode(uri, attrs)); err != nil {
+					if errors.Is(err, SkipDir) {
+						return found, nil
+					}
+					return found, err
+				}
+				continue
+			}
+			uri.Path += "/"
+			err := w.fn(NewNode(uri, true))
+			if errors.Is(err, SkipDir) {
+				continue
+			} else if err != nil {
+				return found, err
+			}
+			if w.opts.Recursive {
+				if _, err := w.walk(ctx, name, "", nil); err != nil {
+					return found, err
+				}
+			}
+		}
+		if next == "" {
+			return found, nil
 		}
 	}
-	return files, nil
 }
 
-func (fs *GCPBucketFS) Get(uri URI) (Node, error) {
+// gcpPageToken splits a page token into object name components relative to prefix, folders keep their "/"
+func gcpPageToken(bucket, prefix, token string) ([]string, error) {
+	if token == "" {
+		return nil, nil
+	}
+	tokenBucket, object := splitGCPPath(token)
+	if tokenBucket != bucket || !strings.HasPrefix(object, prefix) || object == prefix {
+		return nil, ErrInvalidPageToken
+	}
+	keys := strings.SplitAfter(strings.TrimPrefix(object, prefix), "/")
+	if keys[len(keys)-1] == "" {
+		keys = keys[:len(keys)-1]
+	}
+	return keys, nil
+}
+
+func (fs *GCPBucketFS) Get(ctx context.Context, uri URI) (Node, error) {
 	bucket, object := splitGCPPath(uri.Path)
 	object = strings.TrimSuffix(object, "/")
+	if object == "" {
+		// The bucket root is always a directory
+		return NewNode(uri, true), nil
+	}
 
 	it := fs.client.Bucket(bucket).Objects(
-		fs.ctx, &storage.Query{Prefix: object})
+		ctx, &storage.Query{Prefix: object})
 
 	found := false
 	isDir := false
+	var fileAttrs *storage.ObjectAttrs
 
 	for {
 		attrs, err := it.Next()
@@ -133,10 +469,11 @@ func (fs *GCPBucketFS) Get(uri URI) (Node, error) {
 			break
 		}
 		if err != nil {
-			return Node{}, err
+			return Node{}, gcpError("get", uri, err)
 		}
 		if attrs.Name == object {
 			found = true // Exact match, it's a file
+			fileAttrs = attrs
 			break
 		} else if strings.HasPrefix(attrs.Name, object+"/") {
 			found = true
@@ -145,27 +482,181 @@ func (fs *GCPBucketFS) Get(uri URI) (Node, error) {
 		}
 	}
 	if !found {
-		return NewNode(uri, false), ErrNotFound
+		return NewNode(uri, false), gcpError("get", uri, ErrNotFound)
+	}
+	if fileAttrs != nil {
+		return newGCPNode(uri, fileAttrs), nil
 	}
 	return NewNode(uri, isDir), nil
 }
 
-func (fs *GCPBucketFS) MkDir(path URI) (Node, error) {
+// newGCPNode builds a file Node from the attributes of a GCS object
+func newGCPNode(uri URI, attrs *storage.ObjectAttrs) Node {
+	node := NewNode(uri, false)
+	node.Size = attrs.Size
+	node.ModTime = attrs.Updated
+	node.ContentType = attrs.ContentType
+	node.Generation = attrs.Generation
+	node.ETag = attrs.Etag
+	setGCPFileAttrs(&node, attrs.Metadata)
+	// GCS always stores a CRC32C, MD5 is missing for composite objects
+	node.Checksums = map[HashAlgorithm][]byte{
+		CRC32C: binary.BigEndian.AppendUint32(nil, attrs.CRC32C),
+	}
+	if len(attrs.MD5) > 0 {
+		node.Checksums[MD5] = attrs.MD5
+	}
+	return node
+}
+
+// Custom metadata keys of the attributes of a file, the ones gsutil uses
+const (
+	gcpMTimeKey = "goog-reserved-file-mtime"
+	gcpModeKey  = "goog-reserved-posix-mode"
+	gcpUIDKey   = "goog-reserved-posix-uid"
+	gcpGIDKey   = "goog-reserved-posix-gid"
+)
+
+// setGCPFileAttrs replaces the attributes of node by the ones stored in the object metadata
+func setGCPFileAttrs(node *Node, metadata map[string]string) {
+	if mtime, err := strconv.ParseInt(metadata[gcpMTimeKey], 10, 64); err == nil {
+		node.ModTime = time.Unix(mtime, 0)
+	}
+	if mode, err := strconv.ParseUint(metadata[gcpModeKey], 8, 32); err == nil {
+		node.Mode = fs.FileMode(mode).Perm()
+	}
+	uid, uidErr := strconv.Atoi(metadata[gcpUIDKey])
+	gid, gidErr := strconv.Atoi(metadata[gcpGIDKey])
+	if uidErr == nil && gidErr == nil {
+		node.Owner = &Owner{UID: uid, GID: gid}
+	}
+}
+
+// gcpFileAttrsMetadata returns the object metadata storing attrs
+func gcpFileAttrsMetadata(attrs FileAttrs) map[string]string {
+	metadata := map[string]string{}
+	if !attrs.ModTime.IsZero() {
+		metadata[gcpMTimeKey] = strconv.FormatInt(attrs.ModTime.Unix(), 10)
+	}
+	if attrs.Mode != 0 {
+		metadata[gcpModeKey] = strconv.FormatUint(uint64(attrs.Mode.Perm()), 8)
+	}
+	if attrs.Owner != nil {
+		metadata[gcpUIDKey] = strconv.Itoa(attrs.Owner.UID)
+		metadata[gcpGIDKey] = strconv.Itoa(attrs.Owner.GID)
+	}
+	return metadata
+}
+
+/*
+SetAttrs stores the modification time, permissions and owner of a file in
+the custom metadata of its object, with the keys gsutil uses. They are
+reported back as the attributes of the object Node.
+*/
+func (fs *GCPBucketFS) SetAttrs(ctx context.Context, uri URI, attrs FileAttrs) error {
+	metadata := gcpFileAttrsMetadata(attrs)
+	if len(metadata) == 0 {
+		return nil
+	}
+	bucket, object := splitGCPPath(uri.Path)
+	_, err := fs.client.Bucket(bucket).Object(object).Update(ctx, storage.ObjectAttrsToUpdate{Metadata: metadata})
+	return gcpError("setattrs", uri, err)
+}
+
+// GetMetadata returns the metadata of the object, see MetadataStore
+func (fs *GCPBucketFS) GetMetadata(ctx context.Context, uri URI) (Metadata, error) {
+	obj, err := fs.object(uri)
+	if err != nil {
+		return Metadata{}, gcpError("getmetadata", uri, err)
+	}
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return Metadata{}, gcpError("getmetadata", uri, err)
+	}
+	return Metadata{
+		ContentType:     attrs.ContentType,
+		CacheControl:    attrs.CacheControl,
+		ContentEncoding: attrs.ContentEncoding,
+		StorageClass:    attrs.StorageClass,
+		Custom:          attrs.Metadata,
+	}, nil
+}
+
+/*
+SetMetadata updates the metadata of the object, see MetadataStore.
+A new storage class rewrites the object first, GCS can not update it.
+*/
+func (fs *GCPBucketFS) SetMetadata(ctx context.Context, uri URI, md Metadata) error {
+	bucket, object := splitGCPPath(uri.Path)
+	obj := fs.client.Bucket(bucket).Object(object)
+	if md.StorageClass != "" {
+		copier := obj.CopierFrom(obj)
+		copier.StorageClass = md.StorageClass
+		if _, err := copier.Run(ctx); err != nil {
+			return gcpError("setmetadata", uri, err)
+		}
+	}
+	if md.ContentType == "" && md.CacheControl == "" && md.ContentEncoding == "" && len(md.Custom) == 0 {
+		return nil
+	}
+	update := storage.ObjectAttrsToUpdate{Metadata: md.Custom}
+	// A nil field is left unchanged, an empty string would clear it
+	if md.ContentType != "" {
+		update.ContentType = md.ContentType
+	}
+	if md.CacheControl != "" {
+		update.CacheControl = md.CacheControl
+	}
+	if md.ContentEncoding != "" {
+		update.ContentEncoding = md.ContentEncoding
+	}
+	_, err := obj.Update(ctx, update)
+	return gcpError("setmetadata", uri, err)
+}
+
+/*
+MkDir creates the empty marker object of the directory path, ending with a slash.
+
+returns:
+  - ErrAlreadyExists if the marker, an object or a prefix named path exists
+*/
+func (fs *GCPBucketFS) MkDir(ctx context.Context, path URI) (Node, error) {
+	if _, err := fs.Get(ctx, path); err == nil {
+		return Node{}, gcpError("mkdir", path, ErrAlreadyExists)
+	} else if !errors.Is(err, ErrNotFound) {
+		return Node{}, err
+	}
 	// Make sure path ends with a slash
 	if !strings.HasSuffix(path.Path, "/") {
 		path.Path = path.Path + "/"
 	}
-	w, err := fs.Writer(path)
-	if err != nil {
-		return Node{}, err
+	bucket, object := splitGCPPath(path.Path)
+	// The marker created since the check is not overwritten
+	w := fs.client.Bucket(bucket).Object(object).If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	err := w.Close()
+	if errors.Is(gcpErrorKind(err), ErrPrecondition) {
+		return Node{}, gcpError("mkdir", path, ErrAlreadyExists)
 	}
-	defer w.Close()
-	if _, err := io.Copy(w, &bytes.Buffer{}); err != nil {
-		return Node{}, err
+	if err != nil {
+		return Node{}, gcpError("mkdir", path, err)
 	}
 	return NewNode(path, true), nil
 }
 
+// object returns the handle of the object of uri, applying the generation query option
+func (fs *GCPBucketFS) object(uri URI) (*storage.ObjectHandle, error) {
+	bucket, object := splitGCPPath(uri.Path)
+	obj := fs.client.Bucket(bucket).Object(object)
+	if g := uri.Query.Get("generation"); g != "" {
+		generation, err := strconv.ParseInt(g, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w : generation %s", ErrInvalidURI, g)
+		}
+		obj = obj.Generation(generation)
+	}
+	return obj, nil
+}
+
 func splitGCPPath(path string) (bucket string, object string) {
 	tree := strings.Split(path, "/")
 	if len(tree) < 1 {
@@ -1,9 +1,22 @@
 package filesys
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"cloud.google.com/go/storage"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
 )
 
 func TestSplitGCPPath(t *testing.T) {
@@ -28,3 +41,210 @@ func TestSplitGCPPath(t *testing.T) {
 	}
 
 }
+
+func TestNewGCPNode(t *testing.T) {
+	updated := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	attrs := &storage.ObjectAttrs{
+		Bucket:      "bucket",
+		Name:        "folder/object.txt",
+		Size:        42,
+		Updated:     updated,
+		ContentType: "text/plain",
+		MD5:         []byte{0x01, 0x02},
+		CRC32C:      0x0a0b0c0d,
+		Generation:  7,
+		Etag:        "etag",
+	}
+	node := newGCPNode(NewURI(GCPBucketScheme, "bucket/folder/object.txt"), attrs)
+
+	assert.False(t, node.IsDir)
+	assert.Equal(t, "object.txt", node.URI.Name)
+	assert.Equal(t, int64(42), node.Size)
+	assert.Equal(t, updated, node.ModTime)
+	assert.Equal(t, "text/plain", node.ContentType)
+	assert.Equal(t, int64(7), node.Generation)
+	assert.Equal(t, "etag", node.ETag)
+	assert.Equal(t, "0102", node.HexChecksum(MD5))
+	assert.Equal(t, "0a0b0c0d", node.HexChecksum(CRC32C))
+	assert.Equal(t, "", node.HexChecksum(SHA256))
+}
+
+// fakeResumableServer implements the GCS resumable upload protocol for one session
+type fakeResumableServer struct {
+	mu   sync.Mutex
+	data []byte
+	done bool
+	name string
+	// metadata is the object resource of the session
+	metadata gcpObjectResource
+}
+
+func (s *fakeResumableServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Method == http.MethodPost {
+		s.name = r.URL.Query().Get("name")
+		json.NewDecoder(r.Body).Decode(&s.metadata)
+		w.Header().Set("Location", "http://"+r.Host+"/session/1")
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	var start, end int64
+	total := "*"
+	if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%s", &start, &end, &total); err == nil {
+		if start != int64(len(s.data)) {
+			http.Error(w, "bad offset", http.StatusBadRequest)
+			return
+		}
+		s.data = append(s.data, body...)
+	} else {
+		fmt.Sscanf(r.Header.Get("Content-Range"), "bytes */%s", &total)
+	}
+	if total != "*" || s.done {
+		s.done = true
+		fmt.Fprintf(w, `{"size":"%d"}`, len(s.data))
+		return
+	}
+	if len(s.data) > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(s.data)-1))
+	}
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+func TestGCPResumeWriter(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeResumableServer{}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	fs := NewGCPBucketFS(option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+	uri := NewURI(GCPBucketScheme, "bucket/dir/object")
+	data := bytes.Repeat([]byte("0123456789abcdef"), (gcpChunkSize+gcpChunkSize/2)/16)
+
+	// Without an extension, the session starts with the first chunk to sniff it
+	w, err := fs.ResumeWriter(ctx, uri, "", WithCacheControl("no-cache"), WithCustomMetadata("k", "v"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", w.Session())
+	_, err = w.Write(data[:gcpChunkSize+10])
+	assert.NoError(t, err)
+	assert.Equal(t, srv.URL+"/session/1", w.Session())
+	assert.Equal(t, gcpObjectResource{ContentType: "text/plain; charset=utf-8", CacheControl: "no-cache", Metadata: map[string]string{"k": "v"}}, server.metadata)
+	assert.Equal(t, int64(gcpChunkSize), w.Offset())
+	assert.NoError(t, w.Pause())
+	assert.Equal(t, "dir/object", server.name)
+
+	w, err = fs.ResumeWriter(ctx, uri, w.Session())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(gcpChunkSize), w.Offset())
+	_, err = w.Write(data[w.Offset():])
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, data, server.data)
+	assert.Equal(t, int64(len(data)), w.Offset())
+
+	// A complete session is not written again
+	w, err = fs.ResumeWriter(ctx, uri, w.Session())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), w.Offset())
+	assert.NoError(t, w.Close())
+}
+
+func TestGCPResumeWriterSniff(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeResumableServer{}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	fs := NewGCPBucketFS(option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+
+	// A small file is sniffed on Close
+	w, err := fs.ResumeWriter(ctx, NewURI(GCPBucketScheme, "bucket/page"), "")
+	assert.NoError(t, err)
+	_, err = io.WriteString(w, "<html><body></body></html>")
+	assert.NoError(t, err)
+	assert.Equal(t, "", w.Session())
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "text/html; charset=utf-8", server.metadata.ContentType)
+	assert.Equal(t, "<html><body></body></html>", string(server.data))
+
+	// A known content type starts the session at once
+	*server = fakeResumableServer{}
+	w, err = fs.ResumeWriter(ctx, NewURI(GCPBucketScheme, "bucket/data"), "", WithContentType("application/x-custom"))
+	assert.NoError(t, err)
+	assert.Equal(t, srv.URL+"/session/1", w.Session())
+	assert.Equal(t, "application/x-custom", server.metadata.ContentType)
+	assert.NoError(t, w.Pause())
+}
+
+func TestGCPWriterMetadata(t *testing.T) {
+	uri := NewURI(GCPBucketScheme, "bucket/index.html")
+	uri.Query = url.Values{"storage_class": {"NEARLINE"}}
+	md := gcpWriterMetadata(uri, newWriterOptions(nil))
+	assert.Equal(t, Metadata{ContentType: "text/html; charset=utf-8", StorageClass: "NEARLINE"}, md)
+
+	md = gcpWriterMetadata(uri, newWriterOptions([]WriterOption{WithContentType("text/plain"), WithStorageClass("COLDLINE")}))
+	assert.Equal(t, Metadata{ContentType: "text/plain", StorageClass: "COLDLINE"}, md)
+
+	// Sniffed from the content by the writers
+	md = gcpWriterMetadata(NewURI(GCPBucketScheme, "bucket/data"), newWriterOptions(nil))
+	assert.Equal(t, "", md.ContentType)
+}
+
+// fakeMkDirServer lists objects and answers the marker uploads, failing the precondition if exists
+type fakeMkDirServer struct {
+	mu      sync.Mutex
+	objects []string
+	exists  bool
+	uploads []url.Values
+}
+
+func (s *fakeMkDirServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Method == http.MethodGet {
+		var items []map[string]string
+		for _, name := range s.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				items = append(items, map[string]string{"name": name, "bucket": "bucket"})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"kind": "storage#objects", "items": items})
+		return
+	}
+	io.Copy(io.Discard, r.Body)
+	s.uploads = append(s.uploads, r.URL.Query())
+	if s.exists {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		fmt.Fprint(w, `{"error":{"code":412,"message":"conditionNotMet"}}`)
+		return
+	}
+	fmt.Fprint(w, `{"name":"dir/","bucket":"bucket"}`)
+}
+
+func TestGCPMkDir(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeMkDirServer{}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	fs := NewGCPBucketFS(option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+	assert.NoError(t, fs.Connect(ctx))
+	defer fs.Disconnect()
+	uri := NewURI(GCPBucketScheme, "bucket/dir")
+
+	// The marker is only written if it does not exist
+	node, err := fs.MkDir(ctx, uri)
+	assert.NoError(t, err)
+	assert.True(t, node.IsDir)
+	if assert.Len(t, server.uploads, 1) {
+		assert.Equal(t, "0", server.uploads[0].Get("ifGenerationMatch"))
+	}
+
+	// Created since the check
+	server.exists = true
+	_, err = fs.MkDir(ctx, uri)
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	// A prefix of other objects is an existing directory
+	*server = fakeMkDirServer{objects: []string{"dir/a.txt"}}
+	_, err = fs.MkDir(ctx, uri)
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	assert.Empty(t, server.uploads)
+}
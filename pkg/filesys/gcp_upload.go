@@ -0,0 +1,279 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/option/internaloption"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// gcpChunkSize is the size of the chunks of a resumable upload, a multiple of 256 KiB
+const gcpChunkSize = 8 << 20
+
+// gcpUploader is the HTTP client of the resumable uploads, the storage client does not expose their sessions
+type gcpUploader struct {
+	client   *http.Client
+	endpoint string
+	err      error
+}
+
+// uploader returns the HTTP client and the upload endpoint, built once from the client options
+func (fs *GCPBucketFS) uploader() (*http.Client, string, error) {
+	fs.uploadOnce.Do(func() {
+		opts := append([]option.ClientOption{
+			internaloption.WithDefaultEndpoint("https://storage.googleapis.com/storage/v1/"),
+			internaloption.WithDefaultScopes(storage.ScopeFullControl),
+		}, fs.options...)
+		// The client outlives the context of the first upload
+		client, endpoint, err := htransport.NewClient(context.Background(), opts...)
+		endpoint = strings.Replace(endpoint, "/storage/v1", "/upload/storage/v1", 1)
+		fs.upload = gcpUploader{client: client, endpoint: strings.TrimSuffix(endpoint, "/"), err: err}
+	})
+	return fs.upload.client, fs.upload.endpoint, fs.upload.err
+}
+
+// RangeReader reads length bytes of the object from offset, a negative length reads to the end
+func (fs *GCPBucketFS) RangeReader(ctx context.Context, uri URI, offset, length int64) (io.ReadCloser, error) {
+	obj, err := fs.object(uri)
+	if err != nil {
+		return nil, gcpError("open", uri, err)
+	}
+	rc, err := obj.NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return nil, gcpError("open", uri, err)
+	}
+	return gcpReader{Reader: rc, uri: uri}, nil
+}
+
+/*
+ResumeWriter uploads the object with a GCS resumable upload session, the session is its URL.
+
+An empty session starts a new upload, an expired one is started again from
+the first byte. Data is sent in chunks of gcpChunkSize, Offset only counts
+the bytes GCS acknowledged.
+
+The options set the metadata of a new upload like for Writer. Without a
+content type, the session of a new upload only starts once its first chunk
+is buffered, or on Close, to sniff the type from these bytes.
+*/
+func (fs *GCPBucketFS) ResumeWriter(ctx context.Context, uri URI, session string, opts ...WriterOption) (ResumableWriter, error) {
+	client, endpoint, err := fs.uploader()
+	if err != nil {
+		return nil, gcpError("create", uri, err)
+	}
+	w := &gcpResumableWriter{ctx: ctx, client: client, endpoint: endpoint, uri: uri, session: session, metadata: gcpWriterMetadata(uri, newWriterOptions(opts))}
+	if session != "" {
+		err := w.status()
+		if err == nil {
+			return w, nil
+		}
+		if code := gcpStatusCode(err); code != http.StatusNotFound && code != http.StatusGone {
+			return nil, gcpError("create", uri, err)
+		}
+		w.session = ""
+	}
+	if w.metadata.ContentType == "" {
+		return w, nil
+	}
+	if err := w.start(); err != nil {
+		return nil, gcpError("create", uri, err)
+	}
+	return w, nil
+}
+
+// gcpResumableWriter buffers one chunk and sends it to the upload session
+type gcpResumableWriter struct {
+	ctx      context.Context
+	client   *http.Client
+	endpoint string
+	uri      URI
+	// session is empty until the upload starts
+	session string
+	offset  int64
+	buf     []byte
+	// metadata is the metadata of the object of a new session
+	metadata Metadata
+	// done is set if the session upload is already complete
+	done bool
+}
+
+func (w *gcpResumableWriter) Session() string { return w.session }
+func (w *gcpResumableWriter) Offset() int64   { return w.offset }
+
+// Pause drops the buffered data, GCS keeps the acknowledged bytes for a week
+func (w *gcpResumableWriter) Pause() error {
+	w.buf = nil
+	return nil
+}
+
+func (w *gcpResumableWriter) Write(p []byte) (int, error) {
+	if w.done {
+		return 0, gcpError("write", w.uri, ErrAlreadyExists)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= gcpChunkSize && w.session == "" {
+		if err := w.sniffStart(); err != nil {
+			return len(p), gcpError("write", w.uri, err)
+		}
+	}
+	for len(w.buf) >= gcpChunkSize {
+		if err := w.send(w.buf[:gcpChunkSize], -1); err != nil {
+			return len(p), gcpError("write", w.uri, err)
+		}
+	}
+	return len(p), nil
+}
+
+// send puts chunk, failing if GCS does not store any of its bytes
+func (w *gcpResumableWriter) send(chunk []byte, total int64) error {
+	offset := w.offset
+	if err := w.put(chunk, total); err != nil {
+		return err
+	}
+	if !w.done && w.offset == offset {
+		return fmt.Errorf("%w : upload made no progress at byte %d", ErrTransient, offset)
+	}
+	return nil
+}
+
+// Close sends the last bytes and completes the upload
+func (w *gcpResumableWriter) Close() error {
+	if w.done {
+		return nil
+	}
+	if w.session == "" {
+		if err := w.sniffStart(); err != nil {
+			return gcpError("write", w.uri, err)
+		}
+	}
+	total := w.offset + int64(len(w.buf))
+	for !w.done {
+		if err := w.send(w.buf, total); err != nil {
+			return gcpError("write", w.uri, err)
+		}
+	}
+	return nil
+}
+
+// sniffStart detects the content type from the buffered bytes, then starts the session
+func (w *gcpResumableWriter) sniffStart() error {
+	w.metadata.ContentType = http.DetectContentType(w.buf)
+	return w.start()
+}
+
+// start creates a new upload session, the buffered bytes are sent to it from offset 0
+func (w *gcpResumableWriter) start() error {
+	bucket, object := splitGCPPath(w.uri.Path)
+	metadata, err := json.Marshal(gcpObjectResource{
+		StorageClass:    w.metadata.StorageClass,
+		ContentType:     w.metadata.ContentType,
+		CacheControl:    w.metadata.CacheControl,
+		ContentEncoding: w.metadata.ContentEncoding,
+		Metadata:        w.metadata.Custom,
+	})
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf("%s/b/%s/o?uploadType=resumable&name=%s", w.endpoint, url.PathEscape(bucket), url.QueryEscape(object))
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPost, u, bytes.NewReader(metadata))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return err
+	}
+	w.session, w.offset = resp.Header.Get("Location"), 0
+	return nil
+}
+
+// gcpObjectResource is the metadata of the object sent to start an upload session
+type gcpObjectResource struct {
+	StorageClass    string            `json:"storageClass,omitempty"`
+	ContentType     string            `json:"contentType,omitempty"`
+	CacheControl    string            `json:"cacheControl,omitempty"`
+	ContentEncoding string            `json:"contentEncoding,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+}
+
+// status asks the session for the number of bytes it already stored
+func (w *gcpResumableWriter) status() error {
+	return w.put(nil, -2)
+}
+
+/*
+put sends chunk at the current offset and updates it with the acknowledged bytes.
+
+total is the object size for the last chunk, -1 if unknown and -2 for a status request.
+*/
+func (w *gcpResumableWriter) put(chunk []byte, total int64) error {
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPut, w.session, bytes.NewReader(chunk))
+	if err != nil {
+		return err
+	}
+	size := "*"
+	if total >= 0 {
+		size = strconv.FormatInt(total, 10)
+	}
+	if len(chunk) == 0 {
+		req.Header.Set("Content-Range", "bytes */"+size)
+	} else {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%s", w.offset, w.offset+int64(len(chunk))-1, size))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPermanentRedirect:
+		// Incomplete, Range is the bytes stored so far, eg. "bytes=0-1023"
+		offset := int64(0)
+		if r := resp.Header.Get("Range"); r != "" {
+			end, err := strconv.ParseInt(r[strings.LastIndex(r, "-")+1:], 10, 64)
+			if err != nil {
+				return fmt.Errorf("bad upload range %q: %w", r, err)
+			}
+			offset = end + 1
+		}
+		w.buf = w.buf[min(int64(len(w.buf)), offset-w.offset):]
+		w.offset = offset
+		return nil
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated:
+		var attrs struct {
+			Size int64 `json:"size,string"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&attrs); err != nil {
+			return err
+		}
+		w.offset, w.buf, w.done = attrs.Size, nil, true
+		return nil
+	}
+	return googleapi.CheckResponse(resp)
+}
+
+// gcpStatusCode returns the HTTP status of a googleapi error, 0 for other errors
+func gcpStatusCode(err error) int {
+	var e *googleapi.Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return 0
+}
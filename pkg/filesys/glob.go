@@ -0,0 +1,153 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrBadPattern is returned for a malformed glob pattern
+var ErrBadPattern = path.ErrBadPattern
+
+// HasMeta reports whether p has glob special characters
+func HasMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[`)
+}
+
+/*
+Match reports whether name matches the glob pattern, both using "/" separators.
+
+Patterns follow path.Match for each path segment: "*" matches any run of
+characters but "/", "?" one character and "[...]" a character class.
+A "**" segment matches zero or more whole segments.
+
+returns ErrBadPattern if the pattern is malformed
+*/
+func Match(pattern, name string) (bool, error) {
+	segments, err := globSegments(pattern)
+	if err != nil {
+		return false, err
+	}
+	match, _ := matchSegments(segments, strings.Split(name, "/"))
+	return match, nil
+}
+
+// globSegments splits a pattern into segments, checking each of them
+func globSegments(pattern string) ([]string, error) {
+	segments := strings.Split(pattern, "/")
+	for _, segment := range segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, err
+		}
+	}
+	return segments, nil
+}
+
+/*
+matchSegments matches the segments of a name against the ones of a pattern.
+
+match is whether the whole name matches, partial whether a name under
+it could match, ie. a directory with this name is worth walking.
+*/
+func matchSegments(pattern, name []string) (match, partial bool) {
+	if len(pattern) == 0 {
+		return len(name) == 0, false
+	}
+	if pattern[0] == "**" {
+		// "**" matches no segment, or one and maybe more
+		match, partial = matchSegments(pattern[1:], name)
+		if len(name) == 0 {
+			return match, true
+		}
+		m, p := matchSegments(pattern, name[1:])
+		return match || m, partial || p
+	}
+	if len(name) == 0 {
+		return false, true
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false, false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
+
+/*
+Glob returns the nodes matching the glob pattern in the path of uri, see Match.
+
+The walk starts at the literal directory before the first pattern segment,
+and the literal start of that segment is pushed down to the backend with
+WalkOptions.Prefix, so GCS only lists the matching object prefix.
+A uri without pattern returns its own node. Matches are sorted by path.
+
+returns:
+  - ErrBadPattern if the pattern is malformed
+  - ErrNotFound if a uri without pattern does not exist
+*/
+func (c *Client) Glob(ctx context.Context, uri URI) ([]Node, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	fs, err := c.FS(ctx, uri.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return globFS(ctx, fs, uri)
+}
+
+// Glob expands a pattern with the DefaultClient, see Client.Glob
+func Glob(ctx context.Context, uri URI) ([]Node, error) {
+	return DefaultClient.Glob(ctx, uri)
+}
+
+func globFS(ctx context.Context, fs FS, uri URI) ([]Node, error) {
+	if !HasMeta(uri.Path) {
+		node, err := fs.Get(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		return []Node{node}, nil
+	}
+	// The root is the literal part of the path before the segment with the first meta character
+	rootPath, pattern := "", uri.Path
+	if i := strings.LastIndex(uri.Path[:strings.IndexAny(uri.Path, `*?[`)], "/"); i >= 0 {
+		rootPath, pattern = uri.Path[:i+1], uri.Path[i+1:]
+	}
+	segments, err := globSegments(pattern)
+	if err != nil {
+		return nil, &PathError{Op: "glob", URI: uri, Err: err}
+	}
+	if rootPath == "" && uri.Scheme == LocalScheme {
+		rootPath = "."
+	}
+	root := uri.withPath(rootPath)
+	opts := WalkOptions{
+		Recursive: len(segments) > 1 || strings.Contains(pattern, "**"),
+		Prefix:    segments[0][:strings.IndexAny(segments[0]+"*", `*?[\`)],
+	}
+
+	var nodes []Node
+	err = fs.Walk(ctx, root, opts, func(node Node) error {
+		rel, err := root.Rel(node.URI)
+		if err != nil {
+			return nil
+		}
+		match, partial := matchSegments(segments, strings.Split(rel, "/"))
+		if match {
+			nodes = append(nodes, node)
+		}
+		if node.IsDir && !partial {
+			return SkipDir
+		}
+		return nil
+	})
+	// A missing root directory has no match
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].URI.Path < nodes[j].URI.Path })
+	return nodes, nil
+}
@@ -0,0 +1,107 @@
+package filesys
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		pattern, name string
+		match         bool
+	}{
+		{pattern: "*.jpg", name: "a.jpg", match: true},
+		{pattern: "*.jpg", name: "dir/a.jpg", match: false},
+		{pattern: "raw/?.jpg", name: "raw/a.jpg", match: true},
+		{pattern: "raw/?.jpg", name: "raw/ab.jpg", match: false},
+		{pattern: "[ab]*", name: "b.txt", match: true},
+		{pattern: "[ab]*", name: "c.txt", match: false},
+		{pattern: "**/2023-*.gz", name: "2023-01.gz", match: true},
+		{pattern: "**/2023-*.gz", name: "a/b/2023-01.gz", match: true},
+		{pattern: "logs/**/*.gz", name: "logs/x.gz", match: true},
+		{pattern: "logs/**/*.gz", name: "logs/a/b/x.gz", match: true},
+		{pattern: "logs/**/*.gz", name: "other/x.gz", match: false},
+		{pattern: "logs/**", name: "logs/a/b", match: true},
+	}
+	for _, tc := range testCases {
+		match, err := Match(tc.pattern, tc.name)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.match, match, "%s %s", tc.pattern, tc.name)
+	}
+	_, err := Match("[a", "a")
+	assert.ErrorIs(t, err, ErrBadPattern)
+}
+
+func TestGlob(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemFS(0)
+	for _, dir := range []string{"/raw/sub", "/logs/2023/01", "/logs/2024"} {
+		_, err := m.MkDir(ctx, memURI(dir))
+		assert.NoError(t, err)
+	}
+	for _, name := range []string{"/raw/a.jpg", "/raw/b.jpg", "/raw/c.png", "/raw/sub/d.jpg",
+		"/logs/2023-01.gz", "/logs/2023/01/2023-01-01.gz", "/logs/2024/2024-01-01.gz"} {
+		writeMemFile(t, m, name, name)
+	}
+	// Patterns are parsed as given on the command line
+	parse := func(pattern string) URI {
+		uri, err := ParseURI("mem://" + pattern)
+		assert.NoError(t, err)
+		return uri
+	}
+	glob := func(pattern string) []string {
+		nodes, err := globFS(ctx, m, parse(pattern))
+		assert.NoError(t, err)
+		var paths []string
+		for _, node := range nodes {
+			paths = append(paths, node.URI.Path)
+		}
+		return paths
+	}
+	assert.Equal(t, []string{"/raw/a.jpg", "/raw/b.jpg"}, glob("/raw/*.jpg"))
+	assert.Equal(t, []string{"/raw/sub/d.jpg"}, glob("/raw/*/*.jpg"))
+	assert.Equal(t, []string{"/raw/a.jpg", "/raw/b.jpg", "/raw/sub/d.jpg"}, glob("/raw/**/*.jpg"))
+	assert.Equal(t, []string{"/logs/2023-01.gz", "/logs/2023/01/2023-01-01.gz"}, glob("/logs/**/2023-*.gz"))
+	assert.Equal(t, []string{"/logs/2023", "/logs/2024"}, glob("/logs/20??"))
+	assert.Equal(t, []string{"/raw/a.jpg", "/raw/b.jpg"}, glob("/raw/?.jpg"))
+	assert.Equal(t, []string{"/raw/c.png"}, glob("/raw/[c]*"))
+	assert.Equal(t, []string{"/raw/a.jpg"}, glob("/raw/a.jpg"))
+	assert.Empty(t, glob("/missing/*.jpg"))
+
+	_, err := globFS(ctx, m, parse("/raw/[a"))
+	assert.ErrorIs(t, err, ErrBadPattern)
+	_, err = globFS(ctx, m, parse("/raw/missing.jpg"))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestWalkPrefix(t *testing.T) {
+	ctx := context.Background()
+	dir := NewURI(LocalScheme, t.TempDir())
+	local := NewLocalFS()
+	m := NewMemFS(0)
+	for _, fs := range []FS{local, m} {
+		root := dir
+		if fs == FS(m) {
+			root = memURI("/")
+		}
+		_, err := fs.MkDir(ctx, root.Join("ab"))
+		assert.NoError(t, err)
+		_, err = fs.MkDir(ctx, root.Join("b"))
+		assert.NoError(t, err)
+		for _, name := range []string{"a.txt", "ab/c.txt", "b/a.txt"} {
+			w, err := fs.Writer(ctx, root.Join(name))
+			assert.NoError(t, err)
+			assert.NoError(t, w.Close())
+		}
+		var rels []string
+		err = fs.Walk(ctx, root, WalkOptions{Recursive: true, Prefix: "a"}, func(node Node) error {
+			rel, err := root.Rel(node.URI)
+			rels = append(rels, rel)
+			return err
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a.txt", "ab", "ab/c.txt"}, rels)
+	}
+}
@@ -0,0 +1,340 @@
+package filesys
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrInvalidJournal = errors.New("invalid journal")
+
+// journalCheckpoint is the number of bytes written between two journal progress records
+const journalCheckpoint = 8 << 20
+
+/*
+Resumer is implemented by file systems whose uploads can go on after an interruption.
+
+ResumeWriter starts a new upload to uri if session is empty, otherwise it
+continues the upload session returned by a previous ResumableWriter.Session.
+The options set the metadata of the file like for FS.Writer.
+*/
+type Resumer interface {
+	ResumeWriter(ctx context.Context, uri URI, session string, opts ...WriterOption) (ResumableWriter, error)
+}
+
+// ResumableWriter is a writer whose upload can be paused and resumed later
+type ResumableWriter interface {
+	io.WriteCloser
+	// Session identifies the upload, to resume it with Resumer.ResumeWriter
+	Session() string
+	// Offset is the number of bytes already stored by the destination
+	Offset() int64
+	// Pause releases the writer without completing the upload
+	Pause() error
+}
+
+// RangeReader is implemented by file systems that can read a file from an offset
+type RangeReader interface {
+	// RangeReader reads length bytes from offset, a negative length reads to the end
+	RangeReader(ctx context.Context, uri URI, offset, length int64) (io.ReadCloser, error)
+}
+
+/*
+JournalEntry is the progress of one file of a transfer.
+
+Size and ModTime are the source ones, the entry is ignored if the source changed.
+An entry with a Target is not a file but the root of a transfer: the
+path its Dst resolved to on the first run, reused when it is resumed.
+*/
+type JournalEntry struct {
+	Src     string    `json:"src"`
+	Dst     string    `json:"dst"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Offset  int64     `json:"offset"`
+	Session string    `json:"session,omitempty"`
+	Done    bool      `json:"done"`
+	Target  string    `json:"target,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// matches reports whether the entry was recorded for the current version of src
+func (e JournalEntry) matches(src Node) bool {
+	return e.Size == src.Size && e.ModTime.Equal(src.ModTime)
+}
+
+/*
+Journal records the progress of a transfer in a local file, to resume it after an interruption.
+
+The file has one JSON JournalEntry per line and is only appended to,
+the last line of a file wins. It can be inspected with any JSON tool:
+
+	{"src":"/photos/a.jpg","dst":"gs://bucket/photos/a.jpg","size":1024,"mtime":"...","offset":1024,"done":true,"time":"..."}
+*/
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	entries map[[2]string]JournalEntry
+	// targets are the resolved roots of the transfers, keyed by src and dst
+	targets map[[2]string]string
+}
+
+/*
+JournalPath returns the default journal path of a transfer from src to dst,
+in the user cache directory.
+*/
+func JournalPath(src, dst URI) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(src.String() + "\x00" + dst.String()))
+	return filepath.Join(dir, "fileb", "journal", hex.EncodeToString(sum[:8])+".jsonl"), nil
+}
+
+/*
+OpenJournal opens the journal at path to resume a transfer, creating it if needed.
+
+returns:
+  - ErrInvalidJournal if a line of the file is not a JournalEntry
+*/
+func OpenJournal(path string) (*Journal, error) {
+	return openJournal(path, os.O_CREATE|os.O_RDWR|os.O_APPEND)
+}
+
+// CreateJournal creates an empty journal at path, truncating an existing one
+func CreateJournal(path string) (*Journal, error) {
+	return openJournal(path, os.O_CREATE|os.O_RDWR|os.O_APPEND|os.O_TRUNC)
+}
+
+func openJournal(path string, flag int) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, localError("journal", NewURI(LocalScheme, path), err)
+	}
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return nil, localError("journal", NewURI(LocalScheme, path), err)
+	}
+	j := &Journal{path: path, f: f, entries: map[[2]string]JournalEntry{}, targets: map[[2]string]string{}}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			f.Close()
+			return nil, localError("journal", NewURI(LocalScheme, path), fmt.Errorf("%w : line %d: %w", ErrInvalidJournal, line, err))
+		}
+		j.add(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, localError("journal", NewURI(LocalScheme, path), err)
+	}
+	return j, nil
+}
+
+// Path returns the path of the journal file
+func (j *Journal) Path() string { return j.path }
+
+// Entry returns the last entry recorded for the copy of src to dst
+func (j *Journal) Entry(src, dst URI) (JournalEntry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry, ok := j.entries[[2]string{src.String(), dst.String()}]
+	return entry, ok
+}
+
+// Record appends entry to the journal
+func (j *Journal) Record(entry JournalEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.add(entry)
+	_, err = j.f.Write(append(line, '\n'))
+	return localError("journal", NewURI(LocalScheme, j.path), err)
+}
+
+// add keeps entry as the last one of its file or transfer root. j.mu must be held.
+func (j *Journal) add(entry JournalEntry) {
+	key := [2]string{entry.Src, entry.Dst}
+	if entry.Target != "" {
+		j.targets[key] = entry.Target
+		return
+	}
+	j.entries[key] = entry
+}
+
+// Target returns the URI the copy of src to dst resolved to when it was first run
+func (j *Journal) Target(src, dst URI) (URI, bool) {
+	j.mu.Lock()
+	target, ok := j.targets[[2]string{src.String(), dst.String()}]
+	j.mu.Unlock()
+	if !ok {
+		return URI{}, false
+	}
+	uri, err := ParseURI(target)
+	return uri, err == nil
+}
+
+/*
+journalTarget returns the URI src is copied to on fs, see copyTarget.
+
+Resolved once per transfer, the target is recorded in j: a resumed copy
+goes on in the same place even though the first run created dst.
+*/
+func journalTarget(ctx context.Context, j *Journal, fs FS, src Node, dst URI) (URI, error) {
+	if j == nil {
+		return copyTarget(ctx, fs, src, dst)
+	}
+	if target, ok := j.Target(src.URI, dst); ok {
+		return target, nil
+	}
+	target, err := copyTarget(ctx, fs, src, dst)
+	if err != nil {
+		return target, err
+	}
+	return target, j.Record(JournalEntry{Src: src.URI.String(), Dst: dst.String(), Target: target.String()})
+}
+
+// Close closes the journal file, keeping it to resume the transfer
+func (j *Journal) Close() error {
+	return localError("journal", NewURI(LocalScheme, j.path), j.f.Close())
+}
+
+// Remove closes and deletes the journal file, once the transfer is complete
+func (j *Journal) Remove() error {
+	if err := j.Close(); err != nil {
+		return err
+	}
+	return localError("journal", NewURI(LocalScheme, j.path), os.Remove(j.path))
+}
+
+/*
+copyJournaled copies the file src to dst, recording its progress in j.
+
+A file the journal marks as done is skipped. A partial one goes on from
+the offset stored by dstFS if it implements Resumer, otherwise it is
+copied again from the start. If algo is not empty the copy is verified
+before it is marked as done. The source is read through stream.
+*/
+func copyJournaled(ctx context.Context, src Node, dst URI, srcFS, dstFS FS, j *Journal, algo HashAlgorithm, stream streamFunc, wopts ...WriterOption) error {
+	entry, ok := j.Entry(src.URI, dst)
+	if ok && !entry.matches(src) {
+		ok = false
+	}
+	if ok && entry.Done {
+		return nil
+	}
+	entry = JournalEntry{Src: src.URI.String(), Dst: dst.String(), Size: src.Size, ModTime: src.ModTime, Session: entry.Session}
+	if !ok {
+		entry.Session = ""
+	}
+	resumer, canResume := dstFS.(Resumer)
+	if !canResume {
+		var err error
+		if algo != "" {
+			err = copyFileVerified(ctx, src.URI, dst, srcFS, dstFS, algo, stream, wopts...)
+		} else {
+			err = copyFile(ctx, src.URI, dst, srcFS, dstFS, stream, wopts...)
+		}
+		if err != nil {
+			return err
+		}
+		entry.Offset, entry.Done = src.Size, true
+		return j.Record(entry)
+	}
+
+	w, err := resumer.ResumeWriter(ctx, dst, entry.Session, wopts...)
+	if err != nil {
+		return err
+	}
+	entry.Session, entry.Offset = w.Session(), w.Offset()
+	if err := j.Record(entry); err != nil {
+		w.Pause()
+		return err
+	}
+	r, err := rangeReader(ctx, srcFS, src.URI, entry.Offset)
+	if err != nil {
+		w.Pause()
+		return err
+	}
+	defer r.Close()
+	// A resumed copy does not see the whole stream, its source is hashed again
+	var h hash.Hash
+	if algo != "" && entry.Offset == 0 {
+		if h, err = NewHash(algo); err != nil {
+			w.Pause()
+			return err
+		}
+	}
+	cw := &checkpointWriter{ResumableWriter: w, journal: j, entry: entry}
+	if _, err := CopyContext(ctx, hashWriter(cw, h), stream.wrap(ctx, r)); err != nil {
+		w.Pause()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	switch {
+	case h != nil:
+		err = verifyChecksum(ctx, dstFS, dst, algo, h.Sum(nil))
+	case algo != "":
+		err = verifyFile(ctx, src.URI, dst, srcFS, dstFS, algo)
+	}
+	if err != nil {
+		return err
+	}
+	entry.Offset, entry.Done = src.Size, true
+	return j.Record(entry)
+}
+
+// checkpointWriter records the writer offset in the journal every journalCheckpoint bytes
+type checkpointWriter struct {
+	ResumableWriter
+	journal *Journal
+	entry   JournalEntry
+}
+
+func (w *checkpointWriter) Write(p []byte) (int, error) {
+	n, err := w.ResumableWriter.Write(p)
+	// A session may only start once the first bytes are written
+	if offset := w.Offset(); offset-w.entry.Offset >= journalCheckpoint || w.Session() != w.entry.Session {
+		w.entry.Session, w.entry.Offset = w.Session(), offset
+		if err := w.journal.Record(w.entry); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+// rangeReader opens uri from offset, skipping the first bytes if fs can not read ranges
+func rangeReader(ctx context.Context, fs FS, uri URI, offset int64) (io.ReadCloser, error) {
+	if rr, ok := fs.(RangeReader); ok && offset > 0 {
+		return rr.RangeReader(ctx, uri, offset, -1)
+	}
+	r, err := fs.Reader(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, r, offset); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
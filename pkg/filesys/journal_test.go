@@ -0,0 +1,175 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyFS is a MemFS whose next failures readers fail after 5 bytes
+type flakyFS struct {
+	*MemFS
+	failures *atomic.Int32
+	reads    *atomic.Int32
+}
+
+type flakyReader struct {
+	io.ReadCloser
+	read int
+}
+
+var errFlaky = errors.New("connection reset")
+
+func (fs flakyFS) Reader(ctx context.Context, uri URI) (io.ReadCloser, error) {
+	fs.reads.Add(1)
+	r, err := fs.MemFS.Reader(ctx, uri)
+	if err != nil || fs.failures.Add(-1) < 0 {
+		return r, err
+	}
+	return &flakyReader{ReadCloser: r}, nil
+}
+
+func (r *flakyReader) Read(p []byte) (int, error) {
+	if r.read >= 5 {
+		return 0, errFlaky
+	}
+	n, err := r.ReadCloser.Read(p[:min(len(p), 5-r.read)])
+	r.read += n
+	return n, err
+}
+
+func TestJournalResume(t *testing.T) {
+	ctx := context.Background()
+	var failures, reads atomic.Int32
+	src := flakyFS{MemFS: NewMemFS(0), failures: &failures, reads: &reads}
+	_, err := src.MkDir(ctx, memURI("/src"))
+	assert.NoError(t, err)
+	writeMemFile(t, src.MemFS, "/src/a.txt", "first file")
+	writeMemFile(t, src.MemFS, "/src/b.txt", "second file")
+	dst := NewURI(LocalScheme, t.TempDir())
+	journalPath := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	// The first run copies a.txt, b.txt fails after 5 bytes
+	j, err := CreateJournal(journalPath)
+	assert.NoError(t, err)
+	opts := newCopyOptions([]CopyOption{WithJournal(j), WithParallel(1)})
+	err = copyNode(ctx, mustGet(t, src, "/src/a.txt"), dst.Join("a.txt"), src, NewLocalFS(), opts)
+	assert.NoError(t, err)
+	failures.Store(1)
+	err = copyNode(ctx, mustGet(t, src, "/src/b.txt"), dst.Join("b.txt"), src, NewLocalFS(), opts)
+	assert.ErrorIs(t, err, errFlaky)
+	assert.NoError(t, j.Close())
+
+	// The bytes wait in a hidden partial file, b.txt is not created yet
+	_, err = os.Stat(filepath.Join(dst.Path, "b.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	b, err := os.ReadFile(filepath.Join(dst.Path, ".b.txt"+localPartialSuffix))
+	assert.NoError(t, err)
+	assert.Equal(t, "secon", string(b))
+
+	// The second run skips a.txt and goes on with b.txt from byte 5
+	j, err = OpenJournal(journalPath)
+	assert.NoError(t, err)
+	entry, ok := j.Entry(memURI("/src/b.txt"), dst.Join("b.txt"))
+	assert.True(t, ok)
+	assert.False(t, entry.Done)
+	assert.Equal(t, int64(0), entry.Offset)
+	assert.NotEmpty(t, entry.Session)
+
+	reads.Store(0)
+	err = transfer(ctx, memURI("/src"), dst, true, src, NewLocalFS(), newCopyOptions([]CopyOption{WithJournal(j)}))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), reads.Load(), "no full read, b.txt uses a range read")
+	b, err = os.ReadFile(filepath.Join(dst.Path, "b.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "second file", string(b))
+	_, err = os.Stat(filepath.Join(dst.Path, ".b.txt"+localPartialSuffix))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	entry, _ = j.Entry(memURI("/src/b.txt"), dst.Join("b.txt"))
+	assert.True(t, entry.Done)
+	assert.Equal(t, int64(11), entry.Offset)
+
+	// A changed source is copied again
+	writeMemFile(t, src.MemFS, "/src/a.txt", "changed")
+	assert.NoError(t, transfer(ctx, memURI("/src"), dst, true, src, NewLocalFS(), newCopyOptions([]CopyOption{WithJournal(j)})))
+	assert.Equal(t, int32(1), reads.Load())
+	b, err = os.ReadFile(filepath.Join(dst.Path, "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "changed", string(b))
+	assert.NoError(t, j.Remove())
+	_, err = os.Stat(journalPath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestJournalResumeTarget(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+	mem, err := client.FS(ctx, MemScheme)
+	assert.NoError(t, err)
+	_, err = mem.MkDir(ctx, memURI("/a"))
+	assert.NoError(t, err)
+	for _, name := range []string{"1", "2", "3"} {
+		writeMemFile(t, mem.(*MemFS), "/a/"+name, name)
+	}
+	dst := NewURI(LocalScheme, filepath.Join(t.TempDir(), "out"))
+	journalPath := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	// The first run creates dst, the second one resumes into it again
+	j, err := CreateJournal(journalPath)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Copy(ctx, memURI("/a"), dst, true, WithJournal(j)))
+	assert.NoError(t, j.Close())
+	assert.Equal(t, []string{"1", "2", "3"}, dirNames(t, dst.Path))
+
+	// A file copied again would lose this change
+	assert.NoError(t, os.WriteFile(filepath.Join(dst.Path, "1"), []byte("kept"), 0644))
+	j, err = OpenJournal(journalPath)
+	assert.NoError(t, err)
+	defer j.Close()
+	assert.NoError(t, client.Copy(ctx, memURI("/a"), dst, true, WithJournal(j)))
+	assert.Equal(t, []string{"1", "2", "3"}, dirNames(t, dst.Path), "no nested copy")
+	b, err := os.ReadFile(filepath.Join(dst.Path, "1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "kept", string(b))
+}
+
+func TestJournalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := CreateJournal(path)
+	assert.NoError(t, err)
+	assert.NoError(t, j.Record(JournalEntry{Src: "/a", Dst: "mem:///a", Size: 3, Offset: 1}))
+	assert.NoError(t, j.Record(JournalEntry{Src: "/a", Dst: "mem:///a", Size: 3, Offset: 3, Done: true}))
+	assert.NoError(t, j.Close())
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"done":true`)
+
+	j, err = OpenJournal(path)
+	assert.NoError(t, err)
+	entry, ok := j.Entry(NewURI(LocalScheme, "/a"), memURI("/a"))
+	assert.True(t, ok)
+	assert.True(t, entry.Done)
+	assert.NoError(t, j.Close())
+
+	assert.NoError(t, os.WriteFile(path, []byte("{\"src\":\"/a\"}\nnot json\n"), 0644))
+	_, err = OpenJournal(path)
+	assert.ErrorIs(t, err, ErrInvalidJournal)
+}
+
+func mustGet(t *testing.T, fs FS, p string) Node {
+	t.Helper()
+	node, err := fs.Get(context.Background(), memURI(p))
+	assert.NoError(t, err)
+	return node
+}
@@ -1,28 +1,162 @@
 package filesys
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"mime"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
-// LocalFS is a FileSystem implementation that uses the local disk.
-type LocalFS struct{}
+/*
+LocalFS is a FileSystem implementation that uses the local disk.
+
+Files are written atomically: to a temporary file of the same directory,
+synced and renamed to the file path on Close, see Writer.
+
+Symbolic links are handled following Symlinks, see SymlinkPolicy. A
+followed link to one of the directories it is in fails with ErrSymlinkLoop,
+a followed link to nothing with ErrDanglingLink.
+*/
+type LocalFS struct {
+	// InPlace writes the files directly to their path, a failed write leaves a partial file
+	InPlace bool
+	// Symlinks is how the links are listed, read and copied, the empty policy follows them
+	Symlinks SymlinkPolicy
+}
 
 func NewLocalFS() *LocalFS { return &LocalFS{} }
 
-func (LocalFS) Connect() error    { return nil }
-func (LocalFS) Disconnect() error { return nil }
+func (LocalFS) Connect(ctx context.Context) error { return nil }
+func (LocalFS) Disconnect() error                 { return nil }
+
+/*
+Writer creates or replaces the file name, which only changes on Close.
+
+The writer of a done ctx removes its temporary file on Close, leaving the
+file as it was, so a failed copy cancels ctx before closing the writer.
+With InPlace the file is truncated and written directly.
+The content type and custom metadata of opts go to extended attributes, see SetMetadata.
+*/
+func (l LocalFS) Writer(ctx context.Context, name URI, opts ...WriterOption) (io.WriteCloser, error) {
+	return createLocalFile(ctx, name, l.InPlace, newWriterOptions(opts).Metadata)
+}
 
-func (LocalFS) Writer(name URI) (io.WriteCloser, error) {
-	return os.Create(name.Path)
+// Reader opens the file name, a link read as text with SymlinkText reads its target path
+func (l LocalFS) Reader(ctx context.Context, name URI) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, localError("open", name, err)
+	}
+	if target, ok := l.linkText(localPath(name)); ok {
+		return io.NopCloser(strings.NewReader(target)), nil
+	}
+	f, err := os.Open(localPath(name))
+	if err != nil {
+		return nil, localError("open", name, err)
+	}
+	return f, nil
+}
+
+// RangeReader opens the file at offset, a negative length reads to the end
+func (l LocalFS) RangeReader(ctx context.Context, name URI, offset, length int64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, localError("open", name, err)
+	}
+	if target, ok := l.linkText(localPath(name)); ok {
+		r := strings.NewReader(target)
+		r.Seek(offset, io.SeekStart)
+		if length < 0 {
+			return io.NopCloser(r), nil
+		}
+		return io.NopCloser(io.LimitReader(r, length)), nil
+	}
+	f, err := os.Open(localPath(name))
+	if err != nil {
+		return nil, localError("open", name, err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, localError("open", name, err)
+	}
+	if length < 0 {
+		return f, nil
+	}
+	return limitedReadCloser{Reader: io.LimitReader(f, length), Closer: f}, nil
 }
 
-func (LocalFS) Reader(name URI) (io.ReadCloser, error) {
-	return os.Open(name.Path)
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+/*
+ResumeWriter writes to the end of a hidden partial file next to the file,
+renamed to the file path on Close. The partial file path is the session.
+With InPlace the bytes go directly to the end of the file.
+
+An empty session truncates the file, a resumed one goes on after the
+bytes already on disk. The options set its metadata like for Writer.
+*/
+func (l LocalFS) ResumeWriter(ctx context.Context, name URI, session string, opts ...WriterOption) (ResumableWriter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, localError("create", name, err)
+	}
+	path := localPartialPath(name)
+	if l.InPlace {
+		path = localPath(name)
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if session == "" {
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flag, 0666)
+	if err != nil {
+		return nil, localError("create", name, err)
+	}
+	info, err := f.Stat()
+	if err == nil {
+		err = setLocalMetadata(path, newWriterOptions(opts).Metadata)
+	}
+	if err != nil {
+		f.Close()
+		return nil, localError("create", name, err)
+	}
+	return &localResumableWriter{File: f, uri: name, offset: info.Size()}, nil
+}
+
+// localResumableWriter is a file opened in append mode, renamed to uri on Close
+type localResumableWriter struct {
+	*os.File
+	uri    URI
+	offset int64
+}
+
+func (w *localResumableWriter) Write(p []byte) (int, error) {
+	n, err := w.File.Write(p)
+	w.offset += int64(n)
+	return n, err
+}
+
+func (w *localResumableWriter) Session() string { return w.Name() }
+func (w *localResumableWriter) Offset() int64   { return w.offset }
+func (w *localResumableWriter) Pause() error    { return w.File.Close() }
+
+func (w *localResumableWriter) Close() error {
+	if path := localPath(w.uri); w.Name() != path {
+		return localError("create", w.uri, commitLocalFile(w.File, path))
+	}
+	return w.File.Close()
+}
+
+// localError maps an os error to a *PathError
+func localError(op string, uri URI, err error) error {
+	return newPathError(op, uri, err, localErrorKind)
 }
 
 /*
@@ -32,75 +166,117 @@ returns
   - ErrNotFound if file does not exist
   - ErrDirNotEmpty if directory is not empty and recursive is false
 */
-func (l *LocalFS) Delete(name URI, recursive bool) error {
-	info, err := os.Stat(name.Path)
-	if errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("%w : %s", ErrNotFound, name)
+func (l *LocalFS) Delete(ctx context.Context, name URI, recursive bool) error {
+	if err := ctx.Err(); err != nil {
+		return localError("delete", name, err)
+	}
+	info, err := os.Stat(localPath(name))
+	if err != nil {
+		return localError("delete", name, err)
 	}
 	if info.IsDir() && !recursive {
-		empty, err := l.IsEmpty(name)
+		empty, err := l.IsEmpty(ctx, name)
 		if err != nil {
-			return err
+			return localError("delete", name, err)
 		} else if !empty {
-			return fmt.Errorf("%w : %s", ErrDirNotEmpty, name)
+			return localError("delete", name, ErrDirNotEmpty)
 		}
 	}
 	if recursive {
-		return os.RemoveAll(name.Path)
+		return localError("delete", name, os.RemoveAll(localPath(name)))
 	} else {
-		return os.Remove(name.Path)
+		return localError("delete", name, os.Remove(localPath(name)))
 	}
 }
 
 /*
 Use os package to copy file, mapping errors to custom errors
 
+dst is resolved like POSIX cp -r does, see copyTarget. Without recursive
+only the files of a src directory are copied, its sub directories are
+created empty.
+
 returns:
   - ErrNotFound if source file does not exist
+  - ErrInvalidURI if src would be copied onto or inside itself
 */
-func (l *LocalFS) Copy(src, dst URI, recursive bool) error {
-	srcInfo, err := os.Stat(src.Path)
-	if errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("src %w : %s", ErrNotFound, src)
-	} else if err != nil {
-		return err
+func (l *LocalFS) Copy(ctx context.Context, src, dst URI, recursive bool) error {
+	if err := ctx.Err(); err != nil {
+		return localError("copy", src, err)
+	}
+	srcNode, err := l.Get(ctx, src)
+	if err != nil {
+		return localError("copy", src, err)
+	}
+	target, err := copyTarget(ctx, l, srcNode, dst)
+	if err != nil {
+		return localError("copy", dst, err)
 	}
-	dstInfo, err := os.Stat(dst.Path)
-	if !errors.Is(err, fs.ErrNotExist) && !dstInfo.IsDir() {
-		return fmt.Errorf("dst %w : %s", ErrAlreadyExists, dst)
+	if !srcNode.IsDir {
+		return l.copyEntry(ctx, srcNode, target)
 	}
+	return l.copyDir(ctx, src, target, recursive)
+}
 
-	if !srcInfo.IsDir() {
-		return CopyLocalFile(src, dst)
-	} else {
-		entries, err := os.ReadDir(src.Path)
+/*
+Move renames src to dst with os.Rename. Across devices, where a rename
+fails with EXDEV, src is copied to dst then deleted.
+
+returns:
+  - ErrNotFound if src or the parent of dst does not exist
+  - ErrAlreadyExists if dst exists
+*/
+func (l *LocalFS) Move(ctx context.Context, src, dst URI) error {
+	if err := ctx.Err(); err != nil {
+		return localError("move", src, err)
+	}
+	if _, err := os.Lstat(localPath(dst)); err == nil {
+		return localError("move", dst, ErrAlreadyExists)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return localError("move", dst, err)
+	}
+	err := os.Rename(localPath(src), localPath(dst))
+	if !errors.Is(err, syscall.EXDEV) {
+		return localError("move", src, err)
+	}
+	if err := l.Copy(ctx, src, dst, true); err != nil {
+		// dst did not exist, what the copy wrote is removed
+		os.RemoveAll(localPath(dst))
+		return err
+	}
+	return l.Delete(ctx, src, true)
+}
+
+/*
+copyDir copies the content of the directory src into dst, creating it if needed.
+Without recursive only the files of src are copied, its sub directories are
+created empty. The links are copied following the policy of l, see copyEntry.
+*/
+func (l *LocalFS) copyDir(ctx context.Context, src, dst URI, recursive bool) error {
+	if err := os.MkdirAll(localPath(dst), 0755); err != nil {
+		return localError("copy", dst, err)
+	}
+	return l.Walk(ctx, src, WalkOptions{Recursive: recursive}, func(node Node) error {
+		rel, err := src.Rel(node.URI)
 		if err != nil {
-			return err
-		}
-		for _, entry := range entries {
-			srcPath := AppendURIPath(src, entry.Name())
-			dstPath := AppendURIPath(dst, entry.Name())
-			if entry.IsDir() {
-				if err := l.Copy(srcPath, dstPath, true); err != nil {
-					return err
-				}
-			} else {
-				if err := CopyLocalFile(srcPath, dstPath); err != nil {
-					return err
-				}
-			}
+			return localError("copy", node.URI, err)
 		}
-	}
-	return nil
+		return l.copyEntry(ctx, node, dst.Join(rel))
+	})
 }
 
+// Deprecated: use URI.Join
 func AppendURIPath(uri URI, path string) URI {
-	uri.Path = filepath.Join(uri.Path, path)
-	return uri
+	return uri.Join(path)
+}
+
+// localPath returns the path of a local URI in the OS format
+func localPath(uri URI) string {
+	return filepath.FromSlash(uri.Path)
 }
 
 /*
-Use filepath.Walk to list files, mapping errors to custom errors
+Use Walk to list files, mapping errors to custom errors
 
 # If dir is a file, returns a list containing only that file as a node
 
@@ -108,67 +284,238 @@ returns slice of nodes in dir and an error:
   - ErrNotFound if dir does not exist
   - ErrWalk if error walking the path
 */
-func (l *LocalFS) List(dir URI, recursive bool) ([]Node, error) {
-	var files []Node
-	if exists, err := l.Exists(dir); !exists && err == nil {
-		return files, fmt.Errorf("%w : %s", ErrNotFound, dir)
+func (l *LocalFS) List(ctx context.Context, dir URI, recursive bool) ([]Node, error) {
+	return listWalk(ctx, l, dir, recursive)
+}
+
+/*
+Use filepath.WalkDir to stream the nodes under root, in lexical order.
+Symbolic links are listed following Symlinks, a followed link to a
+directory is walked like one. A followed link that is dangling, or that
+would loop, is listed as the link itself, as with SymlinkPreserve.
+
+# If root is a file, fn is called once with that file
+
+returns:
+  - ErrNotFound if root does not exist
+  - ErrWalk if error walking the path
+  - ErrInvalidPageToken if the page token is not a path under root
+  - ErrSymlinkLoop or ErrDanglingLink if root is a followed link that can not be walked
+*/
+func (l *LocalFS) Walk(ctx context.Context, root URI, opts WalkOptions, fn WalkFunc) error {
+	node, err := l.statNode(root)
+	if err != nil {
+		return localError("walk", root, err)
 	}
-	err := filepath.Walk(dir.Path, func(path string, info os.FileInfo, err error) error {
+	if !node.IsDir {
+		return ignoreSkip(fn(node))
+	}
+	token, err := localPageToken(root, opts.PageToken)
+	if err != nil {
+		return localError("walk", root, err)
+	}
+	w := &localWalker{fs: l, ctx: ctx, root: root, token: token, opts: opts, fn: fn}
+	return ignoreSkip(w.walk(localPath(root)))
+}
+
+// localWalker walks a local directory and the directory links it follows
+type localWalker struct {
+	fs    *LocalFS
+	ctx   context.Context
+	root  URI
+	token []string
+	opts  WalkOptions
+	fn    WalkFunc
+	// stopped is set once fn returned SkipAll, filepath.WalkDir only stops the current walk
+	stopped bool
+}
+
+// walk walks dir, root or a followed link under it
+func (w *localWalker) walk(dir string) error {
+	// The trailing separator makes filepath.WalkDir go through a link
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if path == dir {
+			return w.visitErr(path, err)
+		}
+		return w.visit(path, d, err)
+	})
+	if err == nil && w.stopped {
+		return SkipAll
+	}
+	return err
+}
+
+func (w *localWalker) visitErr(path string, err error) error {
+	if err != nil {
+		return localError("walk", w.root.withPath(filepath.ToSlash(path)), fmt.Errorf("%w : %w", ErrWalk, err))
+	}
+	if err := w.ctx.Err(); err != nil {
+		return localError("walk", w.root, err)
+	}
+	return nil
+}
+
+func (w *localWalker) visit(path string, d fs.DirEntry, err error) error {
+	if err := w.visitErr(path, err); err != nil {
+		return err
+	}
+	uri := w.root.withPath(filepath.ToSlash(path))
+	link := d.Type()&fs.ModeSymlink != 0
+	if link && w.fs.Symlinks == SymlinkSkip {
+		return nil
+	}
+	if w.opts.Prefix != "" && filepath.Dir(path) == filepath.Clean(localPath(w.root)) && !strings.HasPrefix(d.Name(), w.opts.Prefix) {
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+	var node Node
+	if link {
+		// A broken link is listed as a link, it only fails the walk as the root
+		node, err = w.fs.statNode(uri)
+		if err != nil && !errors.Is(err, ErrDanglingLink) && !errors.Is(err, ErrSymlinkLoop) {
+			return localError("walk", uri, err)
+		}
+	}
+	// A followed link to a directory is walked below, unless it loops
+	linkDir := link && node.IsDir
+	if linkDir && w.opts.Recursive {
+		loop, err := localLinkLoop(path)
 		if err != nil {
-			return err
+			return localError("walk", uri, fmt.Errorf("%w : %w", ErrWalk, err))
+		}
+		if loop {
+			info, err := os.Lstat(path)
+			if err != nil {
+				return localError("walk", uri, fmt.Errorf("%w : %w", ErrWalk, err))
+			}
+			node, linkDir = linkNode(uri, info, node.LinkTarget), false
 		}
-		if dir.Path == path && info.IsDir() {
+	}
+	if w.token != nil {
+		switch walkPosition(localPathComponents(w.root, path), w.token) {
+		case -1:
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		case 0:
+			// Already visited, but its children may not
+			if linkDir && w.opts.Recursive {
+				return w.walk(path)
+			}
+			if d.IsDir() && !w.opts.Recursive {
+				return filepath.SkipDir
+			}
 			return nil
 		}
-		if info.IsDir() {
-			files = append(files, NewNode(NewURI(dir.Scheme, path), true))
-		} else {
-			files = append(files, NewNode(NewURI(dir.Scheme, path), false))
+	}
+	if !link {
+		info, err := d.Info()
+		// Removed or renamed since its directory was read, eg. a temporary file
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
 		}
-
-		// Skip directories if not recursive mode
-		if !recursive && info.IsDir() {
-			return filepath.SkipDir
+		if err != nil {
+			return localError("walk", uri, fmt.Errorf("%w : %w", ErrWalk, err))
 		}
+		node = newLocalNode(uri, info)
+	}
+	err = w.fn(node)
+	if errors.Is(err, SkipAll) {
+		w.stopped = true
+	}
+	switch {
+	// Skip directories if not recursive mode
+	case err == nil && d.IsDir() && !w.opts.Recursive:
+		return filepath.SkipDir
+	case err == nil && linkDir && w.opts.Recursive:
+		return w.walk(path)
+	case linkDir && errors.Is(err, SkipDir):
 		return nil
-	})
-	if err != nil {
-		return files, fmt.Errorf("%w : %v", ErrWalk, err)
 	}
-	return files, nil
+	return err
 }
 
-// CopyLocalFile copies a single file from src to dst
-func CopyLocalFile(src, dst URI) error {
-	in, err := os.Open(src.Path)
-	if err != nil {
-		return err
+// localPageToken splits a page token into path components relative to root
+func localPageToken(root URI, token string) ([]string, error) {
+	if token == "" {
+		return nil, nil
 	}
-	defer in.Close()
+	rel, err := filepath.Rel(localPath(root), filepath.FromSlash(token))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, ErrInvalidPageToken
+	}
+	return strings.Split(filepath.ToSlash(rel), "/"), nil
+}
 
-	dstInfo, err := os.Stat(dst.Path)
-	if err != nil {
+func localPathComponents(root URI, path string) []string {
+	rel, _ := filepath.Rel(localPath(root), path)
+	return strings.Split(filepath.ToSlash(rel), "/")
+}
+
+/*
+CopyLocalFile copies a single file from src to dst, stops early if ctx is done.
+WithPreserve keeps its attributes, the other options are ignored.
+*/
+func CopyLocalFile(ctx context.Context, src, dst URI, opts ...CopyOption) error {
+	// A missing dst is the new file, an existing directory receives it under its name
+	dstInfo, err := os.Stat(localPath(dst))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return localError("copy", dst, err)
+	}
+	if err == nil && dstInfo.IsDir() {
+		dst = dst.Join(src.Name)
+	}
+	if err := copyLocalFile(ctx, src, dst, false); err != nil {
 		return err
 	}
-	if dstInfo.IsDir() {
-		dst.Path = filepath.Join(dst.Path, filepath.Base(src.Path))
+	if !newCopyOptions(opts).Preserve {
+		return nil
 	}
-	out, err := os.Create(dst.Path)
+	l := NewLocalFS()
+	node, err := l.Get(ctx, src)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-	_, err = io.Copy(out, in)
+	return l.SetAttrs(ctx, dst, node.Attrs())
+}
+
+// copyLocalFile copies the file src to the file dst, atomically unless inPlace is set
+func copyLocalFile(ctx context.Context, src, dst URI, inPlace bool) error {
+	in, err := os.Open(localPath(src))
 	if err != nil {
-		return err
+		return localError("copy", src, err)
 	}
-	return out.Close()
+	defer in.Close()
+
+	// Canceled on failure to drop the temporary file, see createLocalFile
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	out, err := createLocalFile(wctx, dst, inPlace, Metadata{})
+	if err != nil {
+		return localError("copy", dst, err)
+	}
+	_, err = CopyContext(ctx, out, in)
+	if err != nil {
+		cancel()
+		out.Close()
+		return localError("copy", dst, err)
+	}
+	return localError("copy", dst, out.Close())
 }
 
 var ErrWalk = errors.New("error walking the path")
 
-func (l *LocalFS) Exists(path URI) (bool, error) {
-	_, err := os.Stat(path.Path)
+func (l *LocalFS) Exists(ctx context.Context, path URI) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, localError("stat", path, err)
+	}
+	_, err := os.Stat(localPath(path))
 	if err == nil {
 		return true, nil
 	}
@@ -177,33 +524,85 @@ func (l *LocalFS) Exists(path URI) (bool, error) {
 		return false, nil
 	}
 	// Return error if other error
-	return false, err
+	return false, localError("stat", path, err)
 }
 
 /*
-Use os package to get path as a Node.
+Use os package to get path as a Node, a symbolic link is resolved following Symlinks.
 
 Returns:
   - ErrNotFound if path does not exist
+  - ErrDanglingLink or ErrSymlinkLoop if a followed link can not be resolved
 */
-func (l *LocalFS) Get(path URI) (Node, error) {
-	info, err := os.Stat(path.Path)
+func (l *LocalFS) Get(ctx context.Context, path URI) (Node, error) {
+	if err := ctx.Err(); err != nil {
+		return Node{URI: path}, localError("get", path, err)
+	}
+	node, err := l.statNode(path)
 	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
-			return Node{URI: path}, fmt.Errorf("%w : %s", ErrNotFound, path)
+		return Node{URI: path}, localError("get", path, err)
+	}
+	return node, nil
+}
+
+/*
+SetAttrs sets the modification time, permissions and owner of the file path.
+
+The owner is only changed as far as the process is allowed to, a user that
+is not root can usually only set the group of its own files.
+*/
+func (l *LocalFS) SetAttrs(ctx context.Context, path URI, attrs FileAttrs) error {
+	if err := ctx.Err(); err != nil {
+		return localError("setattrs", path, err)
+	}
+	name := localPath(path)
+	if attrs.Owner != nil {
+		err := os.Lchown(name, attrs.Owner.UID, attrs.Owner.GID)
+		if errors.Is(err, fs.ErrPermission) {
+			err = os.Lchown(name, -1, attrs.Owner.GID)
+		}
+		if err != nil && !errors.Is(err, fs.ErrPermission) && !errors.Is(err, errors.ErrUnsupported) {
+			return localError("setattrs", path, err)
+		}
+	}
+	if attrs.Mode != 0 {
+		if err := os.Chmod(name, attrs.Mode.Perm()); err != nil {
+			return localError("setattrs", path, err)
 		}
-		return Node{URI: path}, err
 	}
-	return NewNode(path, info.IsDir()), nil
+	if !attrs.ModTime.IsZero() {
+		if err := os.Chtimes(name, time.Now(), attrs.ModTime); err != nil {
+			return localError("setattrs", path, err)
+		}
+	}
+	return nil
 }
 
-func (l *LocalFS) IsEmpty(path URI) (bool, error) {
-	if _, err := l.Exists(path); err != nil {
+/*
+newLocalNode builds a Node from the file info returned by os.Stat.
+
+The content type is guessed from the file extension, checksums
+are not computed here, use Checksum to hash the file content.
+*/
+func newLocalNode(uri URI, info fs.FileInfo) Node {
+	node := NewNode(uri, info.IsDir())
+	node.ModTime = info.ModTime()
+	node.Mode = info.Mode()
+	node.Owner = localOwner(info)
+	if !info.IsDir() {
+		node.Size = info.Size()
+		node.ContentType = mime.TypeByExtension(filepath.Ext(uri.Path))
+	}
+	return node
+}
+
+func (l *LocalFS) IsEmpty(ctx context.Context, path URI) (bool, error) {
+	if _, err := l.Exists(ctx, path); err != nil {
 		return false, err
 	}
-	dir, err := os.Open(path.Path)
+	dir, err := os.Open(localPath(path))
 	if err != nil {
-		return false, err
+		return false, localError("open", path, err)
 	}
 	defer dir.Close()
 	_, err = dir.Readdir(1)
@@ -212,7 +611,7 @@ func (l *LocalFS) IsEmpty(path URI) (bool, error) {
 	if err == io.EOF {
 		return true, nil
 	}
-	return false, err
+	return false, localError("open", path, err)
 }
 
 /*
@@ -221,17 +620,20 @@ Use os package to create a directory, recursive by default
 Returns newly created Node or error:
   - ErrAlreadyExists if path already exists
 */
-func (l *LocalFS) MkDir(path URI) (Node, error) {
+func (l *LocalFS) MkDir(ctx context.Context, path URI) (Node, error) {
 	node := NewNode(path, true)
-	_, err := os.Stat(path.Path)
+	if err := ctx.Err(); err != nil {
+		return node, localError("mkdir", path, err)
+	}
+	_, err := os.Stat(localPath(path))
 	if errors.Is(err, fs.ErrNotExist) {
-		if err := os.MkdirAll(path.Path, 0755); err != nil {
-			return node, err
+		if err := os.MkdirAll(localPath(path), 0755); err != nil {
+			return node, localError("mkdir", path, err)
 		}
 		return node, nil
 	} else if err != nil {
-		return node, err
+		return node, localError("mkdir", path, err)
 	} else {
-		return node, ErrAlreadyExists
+		return node, localError("mkdir", path, ErrAlreadyExists)
 	}
 }
@@ -1,9 +1,12 @@
 package filesys
 
 import (
+	"context"
+	"encoding/hex"
 	"errors"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -38,7 +41,7 @@ func TestDelete(t *testing.T) {
 				tmpPath := NewTmpDir(t, "", test.src.Path)
 				test.src.Path = tmpPath // update src to tmpPath
 			}
-			err := filesys.Delete(test.src, test.rec)
+			err := filesys.Delete(context.Background(), test.src, test.rec)
 			if err != nil {
 				Assert(t, err, test.err)
 			}
@@ -69,7 +72,7 @@ func TestList(t *testing.T) {
 					test.src.Path = file1 // update src to tmp file 1
 				}
 			}
-			files, err := filesys.List(test.src, test.rec)
+			files, err := filesys.List(context.Background(), test.src, test.rec)
 			if err != nil {
 				Assert(t, err, test.err)
 			}
@@ -106,7 +109,7 @@ func TestCopy(t *testing.T) {
 				dstPath := NewTmpDir(t, "", test.dst.Path)
 				test.dst.Path = dstPath
 			}
-			err := filesys.Copy(test.src, test.dst, test.rec)
+			err := filesys.Copy(context.Background(), test.src, test.dst, test.rec)
 			if err != nil {
 				Assert(t, err, test.err)
 			}
@@ -138,7 +141,7 @@ func TestGet(t *testing.T) {
 				tmpPath := NewTmpDirOrFile(t, test.src.Path, test.isDir)
 				test.src = tmpPath // update src to tmpPath
 			}
-			node, err := filesys.Get(test.src)
+			node, err := filesys.Get(context.Background(), test.src)
 			if err != nil {
 				Assert(t, err, test.err)
 			}
@@ -162,9 +165,9 @@ func TestMkDir(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 			if test.err == ErrAlreadyExists {
-				filesys.MkDir(test.src)
+				filesys.MkDir(context.Background(), test.src)
 			}
-			node, err := filesys.MkDir(test.src)
+			node, err := filesys.MkDir(context.Background(), test.src)
 			Assert(t, err, test.err)
 			PathMustExist(t, test.src.Path)
 			Assert(t, node.URI.Name, test.src.Name)
@@ -255,3 +258,48 @@ func RemoveTmp(t *testing.T, dir string) {
 	}
 	PathMustNotExist(t, dir)
 }
+
+func TestCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tmpPath := NewTmpDir(t, "", "*")
+	defer RemoveTmp(t, tmpPath)
+	NewTmpFile(t, tmpPath, "test1.txt")
+	dir := NewURI(LocalScheme, tmpPath)
+
+	_, err := filesys.List(ctx, dir, true)
+	Assert(t, err, context.Canceled)
+	_, err = filesys.Get(ctx, dir)
+	Assert(t, err, context.Canceled)
+	err = filesys.Delete(ctx, dir, true)
+	Assert(t, err, context.Canceled)
+	PathMustExist(t, tmpPath)
+}
+
+func TestGetMetadata(t *testing.T) {
+	tmpPath := NewTmpDir(t, "", "*")
+	defer RemoveTmp(t, tmpPath)
+	file := filepath.Join(tmpPath, "test.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := filesys.Get(context.Background(), NewURI(LocalScheme, file))
+	Assert(t, err, nil)
+	Assert(t, node.IsDir, false)
+	Assert(t, node.Size, int64(5))
+	Assert(t, node.Mode.Perm(), fs.FileMode(0640))
+	Assert(t, strings.HasPrefix(node.ContentType, "text/plain"), true)
+	Assert(t, node.ModTime.IsZero(), false)
+
+	sum, err := Checksum(context.Background(), NewURI(LocalScheme, file), MD5)
+	Assert(t, err, nil)
+	Assert(t, hex.EncodeToString(sum), "5d41402abc4b2a76b9719d911017c592")
+
+	dir, err := filesys.Get(context.Background(), NewURI(LocalScheme, tmpPath))
+	Assert(t, err, nil)
+	Assert(t, dir.IsDir, true)
+	Assert(t, dir.Mode.IsDir(), true)
+	Assert(t, dir.Size, int64(0))
+}
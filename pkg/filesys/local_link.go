@@ -0,0 +1,221 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+var (
+	ErrInvalidSymlinkPolicy = errors.New("invalid symlink policy")
+	ErrSymlinkLoop          = errors.New("symbolic link loop")
+	ErrDanglingLink         = errors.New("dangling symbolic link")
+)
+
+// SymlinkPolicy is how the local file system handles symbolic links
+type SymlinkPolicy string
+
+const (
+	// SymlinkFollow reads the targets of the links, a link to a directory is walked like one. It is the default.
+	// A dangling or looping link is walked as the link itself.
+	SymlinkFollow SymlinkPolicy = "follow"
+	// SymlinkPreserve reports the links as links, copied as links to the file systems that have them
+	SymlinkPreserve SymlinkPolicy = "preserve"
+	// SymlinkText reports the links as files holding the path of their target, eg. to store them in a bucket
+	SymlinkText SymlinkPolicy = "text"
+	// SymlinkSkip leaves the links out of walks and copies, a link given as the root is still followed
+	SymlinkSkip SymlinkPolicy = "skip"
+)
+
+/*
+ParseSymlinkPolicy parses follow, preserve, text or skip, the empty string is follow.
+
+returns ErrInvalidSymlinkPolicy for another value
+*/
+func ParseSymlinkPolicy(s string) (SymlinkPolicy, error) {
+	switch p := SymlinkPolicy(s); p {
+	case "":
+		return SymlinkFollow, nil
+	case SymlinkFollow, SymlinkPreserve, SymlinkText, SymlinkSkip:
+		return p, nil
+	}
+	return "", fmt.Errorf("%w : %q", ErrInvalidSymlinkPolicy, s)
+}
+
+// WithSymlinks sets how the local file system handles symbolic links
+func WithSymlinks(p SymlinkPolicy) Option {
+	return func(o *Options) { o.Symlinks = p }
+}
+
+/*
+Symlinker is implemented by file systems that can create symbolic links.
+Links reported with SymlinkPreserve are copied with it, as text otherwise.
+*/
+type Symlinker interface {
+	// Symlink creates or replaces link with a symbolic link to target
+	Symlink(ctx context.Context, target string, link URI) error
+}
+
+/*
+statNode returns the node of uri, a symbolic link is resolved following
+the policy of l.
+
+returns:
+  - ErrNotFound if uri does not exist
+  - ErrDanglingLink if the link is followed and its target does not exist
+  - ErrSymlinkLoop if the link is followed and resolves to itself
+
+with these two errors, the node is the link itself, as with SymlinkPreserve
+*/
+func (l *LocalFS) statNode(uri URI) (Node, error) {
+	path := localPath(uri)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return Node{URI: uri}, err
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return newLocalNode(uri, info), nil
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return Node{URI: uri}, err
+	}
+	var node Node
+	switch l.Symlinks {
+	case SymlinkPreserve:
+		node = linkNode(uri, info, target)
+	case SymlinkText:
+		node = newLocalNode(uri, info)
+		node.Mode, node.Size = 0644, int64(len(target))
+	default:
+		targetInfo, err := os.Stat(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return linkNode(uri, info, target), fmt.Errorf("%w : %s -> %s : %w", ErrDanglingLink, path, target, err)
+		case errors.Is(err, syscall.ELOOP):
+			return linkNode(uri, info, target), fmt.Errorf("%w : %s -> %s", ErrSymlinkLoop, path, target)
+		case err != nil:
+			return Node{URI: uri}, err
+		}
+		node = newLocalNode(uri, targetInfo)
+	}
+	node.IsLink, node.LinkTarget = true, target
+	return node, nil
+}
+
+// linkNode returns the node of the link itself, without the info of its target
+func linkNode(uri URI, info fs.FileInfo, target string) Node {
+	node := newLocalNode(uri, info)
+	node.ContentType = ""
+	node.IsLink, node.LinkTarget = true, target
+	return node
+}
+
+// linkText returns the target of the link path if l reads links as text
+func (l LocalFS) linkText(path string) (string, bool) {
+	if l.Symlinks != SymlinkText {
+		return "", false
+	}
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		return "", false
+	}
+	target, err := os.Readlink(path)
+	return target, err == nil
+}
+
+/*
+localLinkLoop reports whether walking the directory link path loops: its
+target is one of the directories path is in, as walked or resolved.
+*/
+func localLinkLoop(path string) (bool, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	target, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return false, err
+	}
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return false, err
+		}
+		if real == target {
+			return true, nil
+		}
+		if filepath.Dir(dir) == dir {
+			return false, nil
+		}
+	}
+}
+
+/*
+Symlink creates or atomically replaces link with a symbolic link to target.
+
+returns ErrNotFound if the parent directory of link does not exist
+*/
+func (l *LocalFS) Symlink(ctx context.Context, target string, link URI) error {
+	if err := ctx.Err(); err != nil {
+		return localError("symlink", link, err)
+	}
+	path := localPath(link)
+	for {
+		tmp := localTempPath(path)
+		err := os.Symlink(target, tmp)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err == nil {
+			if err = os.Rename(tmp, path); err != nil {
+				os.Remove(tmp)
+			}
+		}
+		return localError("symlink", link, err)
+	}
+}
+
+/*
+copyEntry copies a node walked by l to dst within l, following its
+policy: a preserved link is copied as a link, a link read as text as a file.
+*/
+func (l *LocalFS) copyEntry(ctx context.Context, node Node, dst URI) error {
+	switch {
+	case node.IsDir:
+		return localError("copy", dst, os.MkdirAll(localPath(dst), 0755))
+	case node.Mode&fs.ModeSymlink != 0:
+		return l.Symlink(ctx, node.LinkTarget, dst)
+	case node.IsLink && l.Symlinks == SymlinkText:
+		return copyFile(ctx, node.URI, dst, l, l, nil)
+	}
+	return copyLocalFile(ctx, node.URI, dst, l.InPlace)
+}
+
+/*
+copyLink copies the link src, reported with SymlinkPreserve, to dst:
+as a link if dstFS is a Symlinker, otherwise as a file holding its target.
+*/
+func copyLink(ctx context.Context, src Node, dst URI, dstFS FS, wopts ...WriterOption) error {
+	if linker, ok := dstFS.(Symlinker); ok {
+		return linker.Symlink(ctx, src.LinkTarget, dst)
+	}
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w, err := dstFS.Writer(wctx, dst, wopts...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, strings.NewReader(src.LinkTarget)); err != nil {
+		cancel()
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,197 @@
+package filesys
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// linkTree creates a tree with a link to a file, to a directory and a dangling one
+func linkTree(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges")
+	}
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "src", "sub"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "src", "a.txt"), []byte("a"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "src", "sub", "b.txt"), []byte("bb"), 0644))
+	assert.NoError(t, os.Symlink("a.txt", filepath.Join(dir, "src", "file.lnk")))
+	assert.NoError(t, os.Symlink("sub", filepath.Join(dir, "src", "dir.lnk")))
+	return dir
+}
+
+// walkLinks walks root recursively and returns the nodes by path relative to root
+func walkLinks(t *testing.T, policy SymlinkPolicy, root string) (map[string]Node, error) {
+	nodes := map[string]Node{}
+	rootURI := NewURI(LocalScheme, root)
+	err := (&LocalFS{Symlinks: policy}).Walk(context.Background(), rootURI, WalkOptions{Recursive: true}, func(node Node) error {
+		rel, err := rootURI.Rel(node.URI)
+		assert.NoError(t, err)
+		nodes[rel] = node
+		return nil
+	})
+	return nodes, err
+}
+
+func TestLocalSymlinkWalk(t *testing.T) {
+	dir := linkTree(t)
+	src := filepath.Join(dir, "src")
+
+	for _, policy := range []SymlinkPolicy{"", SymlinkFollow} {
+		nodes, err := walkLinks(t, policy, src)
+		assert.NoError(t, err)
+		assert.Len(t, nodes, 6)
+		assert.True(t, nodes["dir.lnk"].IsDir)
+		assert.True(t, nodes["dir.lnk"].IsLink)
+		assert.Equal(t, "sub", nodes["dir.lnk"].LinkTarget)
+		assert.Equal(t, int64(2), nodes["dir.lnk/b.txt"].Size)
+		assert.Equal(t, int64(1), nodes["file.lnk"].Size)
+		assert.False(t, nodes["a.txt"].IsLink)
+	}
+
+	nodes, err := walkLinks(t, SymlinkPreserve, src)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 5)
+	assert.False(t, nodes["dir.lnk"].IsDir)
+	assert.NotZero(t, nodes["dir.lnk"].Mode&fs.ModeSymlink)
+	assert.Equal(t, "a.txt", nodes["file.lnk"].LinkTarget)
+
+	nodes, err = walkLinks(t, SymlinkText, src)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 5)
+	assert.Equal(t, int64(len("sub")), nodes["dir.lnk"].Size)
+	assert.Zero(t, nodes["dir.lnk"].Mode&fs.ModeSymlink)
+	r, err := (&LocalFS{Symlinks: SymlinkText}).Reader(context.Background(), NewURI(LocalScheme, filepath.Join(src, "dir.lnk")))
+	if assert.NoError(t, err) {
+		b, _ := io.ReadAll(r)
+		r.Close()
+		assert.Equal(t, "sub", string(b))
+	}
+
+	nodes, err = walkLinks(t, SymlinkSkip, src)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 3)
+	assert.NotContains(t, nodes, "file.lnk")
+
+	// A link given as the root is walked
+	assert.NoError(t, os.Symlink("src", filepath.Join(dir, "root.lnk")))
+	nodes, err = walkLinks(t, SymlinkSkip, filepath.Join(dir, "root.lnk"))
+	assert.NoError(t, err)
+	assert.Contains(t, nodes, "sub/b.txt")
+}
+
+func TestLocalSymlinkErrors(t *testing.T) {
+	dir := linkTree(t)
+	src := filepath.Join(dir, "src")
+
+	// A followed link that can not be walked is listed as the link itself
+	isLink := func(node Node, target string) {
+		t.Helper()
+		assert.True(t, node.IsLink)
+		assert.False(t, node.IsDir)
+		assert.NotZero(t, node.Mode&fs.ModeSymlink)
+		assert.Equal(t, target, node.LinkTarget)
+	}
+
+	// A link to one of its parents
+	assert.NoError(t, os.Symlink("..", filepath.Join(src, "sub", "up.lnk")))
+	nodes, err := walkLinks(t, SymlinkFollow, src)
+	assert.NoError(t, err)
+	isLink(nodes["sub/up.lnk"], "..")
+	assert.NotContains(t, nodes, "sub/up.lnk/a.txt")
+	_, err = walkLinks(t, SymlinkPreserve, src)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Remove(filepath.Join(src, "sub", "up.lnk")))
+
+	// Links to each other
+	assert.NoError(t, os.Symlink("loop2.lnk", filepath.Join(src, "loop1.lnk")))
+	assert.NoError(t, os.Symlink("loop1.lnk", filepath.Join(src, "loop2.lnk")))
+	nodes, err = walkLinks(t, SymlinkFollow, src)
+	assert.NoError(t, err)
+	isLink(nodes["loop1.lnk"], "loop2.lnk")
+	_, err = walkLinks(t, SymlinkText, src)
+	assert.NoError(t, err)
+	_, err = walkLinks(t, SymlinkFollow, filepath.Join(src, "loop1.lnk"))
+	assert.ErrorIs(t, err, ErrSymlinkLoop)
+	assert.NoError(t, os.Remove(filepath.Join(src, "loop1.lnk")))
+	assert.NoError(t, os.Remove(filepath.Join(src, "loop2.lnk")))
+
+	// A dangling link next to a good file
+	assert.NoError(t, os.Symlink("missing", filepath.Join(src, "dangling.lnk")))
+	nodes, err = walkLinks(t, SymlinkFollow, src)
+	assert.NoError(t, err)
+	isLink(nodes["dangling.lnk"], "missing")
+	assert.Equal(t, int64(1), nodes["a.txt"].Size)
+	_, err = NewLocalFS().Get(context.Background(), NewURI(LocalScheme, filepath.Join(src, "dangling.lnk")))
+	assert.ErrorIs(t, err, ErrDanglingLink)
+	_, err = walkLinks(t, SymlinkFollow, filepath.Join(src, "dangling.lnk"))
+	assert.ErrorIs(t, err, ErrDanglingLink)
+	_, err = walkLinks(t, SymlinkSkip, src)
+	assert.NoError(t, err)
+
+	// Copied, it stays a link
+	client := NewClient()
+	defer client.Close()
+	assert.NoError(t, client.Copy(context.Background(), NewURI(LocalScheme, src), NewURI(LocalScheme, filepath.Join(dir, "copy")), true))
+	target, err := os.Readlink(filepath.Join(dir, "copy", "dangling.lnk"))
+	assert.NoError(t, err)
+	assert.Equal(t, "missing", target)
+	b, err := os.ReadFile(filepath.Join(dir, "copy", "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(b))
+}
+
+func TestLocalSymlinkCopy(t *testing.T) {
+	ctx := context.Background()
+	dir := linkTree(t)
+	local := func(p string) URI { return NewURI(LocalScheme, filepath.Join(dir, p)) }
+
+	client := NewClient(WithSymlinks(SymlinkPreserve))
+	defer client.Close()
+	assert.NoError(t, client.Copy(ctx, local("src"), local("copy"), true))
+	target, err := os.Readlink(filepath.Join(dir, "copy", "dir.lnk"))
+	assert.NoError(t, err)
+	assert.Equal(t, "sub", target)
+	assert.NoError(t, client.Copy(ctx, local("src/file.lnk"), local("single.lnk"), false))
+	target, err = os.Readlink(filepath.Join(dir, "single.lnk"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a.txt", target)
+
+	// Object stores without links get the target path
+	assert.NoError(t, client.Copy(ctx, local("src"), memURI("/src"), true))
+	mem, err := client.FS(ctx, MemScheme)
+	assert.NoError(t, err)
+	assert.Equal(t, "sub", readMemFile(t, mem.(*MemFS), "/src/dir.lnk"))
+
+	// Followed, the link is copied as its target
+	follow := NewClient()
+	defer follow.Close()
+	assert.NoError(t, follow.Copy(ctx, local("src"), local("followed"), true))
+	b, err := os.ReadFile(filepath.Join(dir, "followed", "dir.lnk", "b.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "bb", string(b))
+	info, err := os.Lstat(filepath.Join(dir, "followed", "dir.lnk"))
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	skip := NewClient(WithSymlinks(SymlinkSkip))
+	defer skip.Close()
+	assert.NoError(t, skip.Copy(ctx, local("src"), local("skipped"), true))
+	assert.Equal(t, []string{"a.txt", "sub"}, dirNames(t, filepath.Join(dir, "skipped")))
+}
+
+func TestParseSymlinkPolicy(t *testing.T) {
+	for s, want := range map[string]SymlinkPolicy{"": SymlinkFollow, "follow": SymlinkFollow, "preserve": SymlinkPreserve, "text": SymlinkText, "skip": SymlinkSkip} {
+		p, err := ParseSymlinkPolicy(s)
+		assert.NoError(t, err)
+		assert.Equal(t, want, p)
+	}
+	_, err := ParseSymlinkPolicy("copy")
+	assert.ErrorIs(t, err, ErrInvalidSymlinkPolicy)
+}
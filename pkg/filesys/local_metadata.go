@@ -0,0 +1,102 @@
+package filesys
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// localXattrPrefix is the namespace of the extended attributes holding the metadata of local files
+	localXattrPrefix = "user."
+	// localMimeTypeKey holds the content type, the attribute of the freedesktop shared MIME-info spec
+	localMimeTypeKey = "mime_type"
+)
+
+/*
+GetMetadata returns the content type and the custom metadata of a file,
+stored in its user extended attributes, see MetadataStore. Without a
+stored content type, it is detected from the extension or the content.
+
+returns ErrNotFound if path does not exist
+*/
+func (l *LocalFS) GetMetadata(ctx context.Context, path URI) (Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return Metadata{}, localError("getmetadata", path, err)
+	}
+	info, err := os.Stat(localPath(path))
+	if err != nil {
+		return Metadata{}, localError("getmetadata", path, err)
+	}
+	attrs, err := localXattrs(localPath(path))
+	if err != nil {
+		return Metadata{}, localError("getmetadata", path, err)
+	}
+	var md Metadata
+	for key, value := range attrs {
+		if key == localMimeTypeKey {
+			md.ContentType = value
+			continue
+		}
+		if md.Custom == nil {
+			md.Custom = map[string]string{}
+		}
+		md.Custom[key] = value
+	}
+	if md.ContentType == "" && !info.IsDir() {
+		md.ContentType, err = localContentType(localPath(path))
+		if err != nil {
+			return Metadata{}, localError("getmetadata", path, err)
+		}
+	}
+	return md, nil
+}
+
+/*
+SetMetadata stores the content type and the custom metadata of md in the
+user extended attributes of the file, see MetadataStore. The other fields
+have no local equivalent and are ignored.
+
+returns:
+  - ErrNotFound if path does not exist
+  - an error wrapping errors.ErrUnsupported if the file system has no extended attributes
+*/
+func (l *LocalFS) SetMetadata(ctx context.Context, path URI, md Metadata) error {
+	if err := ctx.Err(); err != nil {
+		return localError("setmetadata", path, err)
+	}
+	return localError("setmetadata", path, setLocalMetadata(localPath(path), md))
+}
+
+// setLocalMetadata stores the content type and the custom metadata of md in the extended attributes of path
+func setLocalMetadata(path string, md Metadata) error {
+	if md.ContentType != "" {
+		if err := setLocalXattr(path, localMimeTypeKey, md.ContentType); err != nil {
+			return err
+		}
+	}
+	for key, value := range md.Custom {
+		if err := setLocalXattr(path, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localContentType detects the content type of the file path from its extension, or its first bytes
+func localContentType(path string) (string, error) {
+	if contentType := detectContentType(filepath.Base(path), nil); contentType != "" {
+		return contentType, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head, err := io.ReadAll(io.LimitReader(f, 512))
+	if err != nil {
+		return "", err
+	}
+	return detectContentType(filepath.Base(path), head), nil
+}
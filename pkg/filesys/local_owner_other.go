@@ -0,0 +1,10 @@
+//go:build !unix
+
+package filesys
+
+import "io/fs"
+
+// localOwner returns nil, files have no numeric owner on this platform
+func localOwner(info fs.FileInfo) *Owner {
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package filesys
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// localOwner returns the owner of a file from its os.Stat info
+func localOwner(info fs.FileInfo) *Owner {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return &Owner{UID: int(stat.Uid), GID: int(stat.Gid)}
+}
@@ -0,0 +1,136 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	// localTempSuffix ends the name of the temporary file of an atomic write, only a crash leaves one behind
+	localTempSuffix = ".fileb-tmp"
+	// localPartialSuffix ends the name of the file a paused resumable write keeps its bytes in
+	localPartialSuffix = ".fileb-partial"
+)
+
+/*
+createLocalFile opens a writer to the file uri.
+
+Unless inPlace is set, the content goes to a temporary file of the same
+directory, synced and renamed to the file path on Close, so the file is
+never seen partially written and a failed write leaves it as it was.
+A writer closed once ctx is done removes its temporary file instead.
+
+An existing file keeps its permissions, a new one is created like os.Create does.
+The metadata md is stored in its extended attributes, see setLocalMetadata.
+*/
+func createLocalFile(ctx context.Context, uri URI, inPlace bool, md Metadata) (io.WriteCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, localError("create", uri, err)
+	}
+	path := localPath(uri)
+	if inPlace {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, localError("create", uri, err)
+		}
+		if err := setLocalMetadata(path, md); err != nil {
+			f.Close()
+			return nil, localError("create", uri, err)
+		}
+		return f, nil
+	}
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return nil, localError("create", uri, &fs.PathError{Op: "open", Path: path, Err: syscall.EISDIR})
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return nil, localError("create", uri, err)
+	}
+	f, err := createLocalTemp(path)
+	if err != nil {
+		return nil, localError("create", uri, err)
+	}
+	if info != nil {
+		err = f.Chmod(info.Mode().Perm())
+	}
+	if err == nil {
+		err = setLocalMetadata(f.Name(), md)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, localError("create", uri, err)
+	}
+	return &localAtomicWriter{File: f, ctx: ctx, uri: uri, path: path}, nil
+}
+
+// createLocalTemp creates a new hidden temporary file next to path
+func createLocalTemp(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(localTempPath(path), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if !errors.Is(err, fs.ErrExist) {
+			return f, err
+		}
+	}
+}
+
+// localTempPath returns a random hidden temporary path next to path
+func localTempPath(path string) string {
+	dir, name := filepath.Split(path)
+	return filepath.Join(dir, fmt.Sprintf(".%s.%08x%s", name, rand.Uint32(), localTempSuffix))
+}
+
+// localPartialPath returns the path of the file the resumable writes to uri go to
+func localPartialPath(uri URI) string {
+	dir, name := filepath.Split(localPath(uri))
+	return filepath.Join(dir, "."+name+localPartialSuffix)
+}
+
+// localAtomicWriter writes to a temporary file renamed to path on Close
+type localAtomicWriter struct {
+	*os.File
+	ctx    context.Context
+	uri    URI
+	path   string
+	closed bool
+}
+
+/*
+Close publishes the file: the temporary file is synced then renamed to the
+file path. If ctx is done, the temporary file is removed and ctx.Err() returned.
+*/
+func (w *localAtomicWriter) Close() error {
+	if w.closed {
+		return localError("create", w.uri, os.ErrClosed)
+	}
+	w.closed = true
+	if err := w.ctx.Err(); err != nil {
+		w.File.Close()
+		os.Remove(w.Name())
+		return localError("create", w.uri, err)
+	}
+	err := commitLocalFile(w.File, w.path)
+	if err != nil {
+		os.Remove(w.Name())
+	}
+	return localError("create", w.uri, err)
+}
+
+// commitLocalFile syncs and closes f, then renames it to path
+func commitLocalFile(f *os.File, path string) error {
+	err := f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,107 @@
+package filesys
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dirNames returns the names of the entries of dir
+func dirNames(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestLocalAtomicWriter(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("old"), 0640))
+	uri := NewURI(LocalScheme, path)
+
+	// The file changes on Close only, keeping its permissions
+	w, err := NewLocalFS().Writer(ctx, uri)
+	assert.NoError(t, err)
+	_, err = io.WriteString(w, "new content")
+	assert.NoError(t, err)
+	b, _ := os.ReadFile(path)
+	assert.Equal(t, "old", string(b))
+	assert.NoError(t, w.Close())
+	b, _ = os.ReadFile(path)
+	assert.Equal(t, "new content", string(b))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0640), info.Mode().Perm())
+	assert.Equal(t, []string{"a.txt"}, dirNames(t, dir))
+
+	// A writer closed once its context is done leaves the file as it was
+	wctx, cancel := context.WithCancel(ctx)
+	w, err = NewLocalFS().Writer(wctx, uri)
+	assert.NoError(t, err)
+	_, err = io.WriteString(w, "partial")
+	assert.NoError(t, err)
+	cancel()
+	assert.ErrorIs(t, w.Close(), context.Canceled)
+	b, _ = os.ReadFile(path)
+	assert.Equal(t, "new content", string(b))
+	assert.Equal(t, []string{"a.txt"}, dirNames(t, dir))
+
+	// In place, the file is truncated and written directly
+	w, err = (&LocalFS{InPlace: true}).Writer(ctx, uri)
+	assert.NoError(t, err)
+	_, err = io.WriteString(w, "in place")
+	assert.NoError(t, err)
+	b, _ = os.ReadFile(path)
+	assert.Equal(t, "in place", string(b))
+	assert.NoError(t, w.Close())
+
+	_, err = NewLocalFS().Writer(ctx, NewURI(LocalScheme, dir))
+	assert.Error(t, err)
+	_, err = NewLocalFS().Writer(ctx, NewURI(LocalScheme, filepath.Join(dir, "missing", "b.txt")))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestLocalAtomicCopy(t *testing.T) {
+	ctx := context.Background()
+	src := NewMemFS(0)
+	writeMemFile(t, src, "/a.txt", "0123456789")
+	failures := &atomic.Int32{}
+	flaky := flakyFS{MemFS: src, failures: failures, reads: &atomic.Int32{}}
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("old"), 0644))
+
+	// A failed copy leaves no partial file, and an existing file unchanged
+	for _, name := range []string{"new.txt", "old.txt"} {
+		failures.Store(1)
+		err := copyNode(ctx, mustGet(t, src, "/a.txt"), NewURI(LocalScheme, filepath.Join(dir, name)), flaky, NewLocalFS(), CopyOptions{})
+		assert.ErrorIs(t, err, errFlaky)
+	}
+	assert.Equal(t, []string{"old.txt"}, dirNames(t, dir))
+	b, _ := os.ReadFile(filepath.Join(dir, "old.txt"))
+	assert.Equal(t, "old", string(b))
+
+	// Written in place, the partial file stays
+	failures.Store(1)
+	err := copyNode(ctx, mustGet(t, src, "/a.txt"), NewURI(LocalScheme, filepath.Join(dir, "old.txt")), flaky, &LocalFS{InPlace: true}, CopyOptions{})
+	assert.ErrorIs(t, err, errFlaky)
+	b, _ = os.ReadFile(filepath.Join(dir, "old.txt"))
+	assert.Equal(t, "01234", string(b))
+
+	// The native copy is atomic too
+	local := NewLocalFS()
+	assert.NoError(t, local.Copy(ctx, NewURI(LocalScheme, filepath.Join(dir, "old.txt")), NewURI(LocalScheme, filepath.Join(dir, "copy.txt")), false))
+	b, _ = os.ReadFile(filepath.Join(dir, "copy.txt"))
+	assert.Equal(t, "01234", string(b))
+	assert.Equal(t, []string{"copy.txt", "old.txt"}, dirNames(t, dir))
+}
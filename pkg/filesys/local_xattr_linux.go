@@ -0,0 +1,57 @@
+//go:build linux
+
+package filesys
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// localXattrs returns the user extended attributes of the file path, without their prefix
+func localXattrs(path string) (map[string]string, error) {
+	names, err := localXattrRead(func(buf []byte) (int, error) { return syscall.Listxattr(path, buf) })
+	if err != nil {
+		return nil, os.NewSyscallError("listxattr", err)
+	}
+	attrs := map[string]string{}
+	for _, name := range bytes.Split(names, []byte{0}) {
+		key, ok := strings.CutPrefix(string(name), localXattrPrefix)
+		if !ok {
+			continue
+		}
+		value, err := localXattrRead(func(buf []byte) (int, error) { return syscall.Getxattr(path, string(name), buf) })
+		// Removed since it was listed
+		if errors.Is(err, syscall.ENODATA) {
+			continue
+		}
+		if err != nil {
+			return nil, os.NewSyscallError("getxattr", err)
+		}
+		attrs[key] = string(value)
+	}
+	return attrs, nil
+}
+
+// localXattrRead calls read with a buffer of the size it asks for, again if it grew meanwhile
+func localXattrRead(read func(buf []byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := read(nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := read(buf)
+		if errors.Is(err, syscall.ERANGE) {
+			continue
+		}
+		return buf[:n], err
+	}
+}
+
+// setLocalXattr sets the user extended attribute key of the file path
+func setLocalXattr(path, key, value string) error {
+	return os.NewSyscallError("setxattr", syscall.Setxattr(path, localXattrPrefix+key, []byte(value), 0))
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package filesys
+
+import (
+	"errors"
+	"os"
+)
+
+// localXattrs returns no attribute, they are only supported on Linux
+func localXattrs(path string) (map[string]string, error) {
+	return nil, nil
+}
+
+// setLocalXattr fails with errors.ErrUnsupported, extended attributes are only supported on Linux
+func setLocalXattr(path, key, value string) error {
+	return os.NewSyscallError("setxattr", errors.ErrUnsupported)
+}
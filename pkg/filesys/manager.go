@@ -1,145 +1,558 @@
 package filesys
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"io"
-	"path"
 )
 
 /*
 Copy copies a file from one filesystem to another.
 
+dst is resolved like POSIX cp -r does: an existing directory receives src
+under its own name, otherwise src is copied to dst. Files keep their path
+relative to src.
+
 If both filesystems are the same, use the filesystem's copy method.
+WithJournal only applies to copies between different filesystems.
+WithFilter only copies the files of a src directory it keeps.
+WithVerify checks every file, a mismatch is a *ChecksumError.
+
+If the filesystems are different, the files are streamed from one to the
+other by a pool of workers, see WithParallel. A failed file does not stop
+the others, the failures are returned in a *TransferError.
 
-If the filesystems are different, perform a manual copy:
-  - get the source file
-  - create the destination file
+Each file is retried with the retry policy of the client, or WithRetry.
+The copy stops and returns ctx.Err() once ctx is done.
 */
-func Copy(src, dst URI, recursive bool) error {
-	srcFS := SchemeFS(src.Scheme)
-	dstFS := SchemeFS(dst.Scheme)
-	err := connectFilesystems(srcFS, dstFS)
+func (c *Client) Copy(ctx context.Context, src, dst URI, recursive bool, opts ...CopyOption) error {
+	release, err := c.acquire(ctx)
 	if err != nil {
 		return err
 	}
-	defer disconnectFilesystems(srcFS, dstFS)
+	defer release()
+	return c.copy(ctx, src, dst, recursive, c.copyOptions(opts))
+}
 
-	// If the destination is a directory, add the source file name to the destination path
-	srcNode, err := srcFS.Get(src)
-	if err != nil && !errors.Is(err, ErrNotFound) {
-		return fmt.Errorf("failed to get source file %s: %w", src.String(), err)
-	} else if errors.Is(err, ErrNotFound) {
-		return fmt.Errorf("source file %s does not exist", src.String())
+// copyOptions returns the options of a copy, with the retry policy of the client by default
+func (c *Client) copyOptions(opts []CopyOption) CopyOptions {
+	o := newCopyOptions(opts)
+	if o.Retry == nil {
+		policy := c.opts.Retry
+		o.Retry = &policy
 	}
-	if srcNode.IsDir {
-		dst.Path = path.Join(dst.Path, srcNode.URI.Name)
-		dstFS.MkDir(dst)
+	return o
+}
+
+func (c *Client) copy(ctx context.Context, src, dst URI, recursive bool, opts CopyOptions) error {
+	srcFS, err := c.FS(ctx, src.Scheme)
+	if err != nil {
+		return err
+	}
+	dstFS, err := c.FS(ctx, dst.Scheme)
+	if err != nil {
+		return err
+	}
+
+	var srcNode Node
+	err = c.opts.Retry.Do(ctx, func() (err error) {
+		srcNode, err = srcFS.Get(ctx, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if opts.Verify && opts.Hash == "" {
+		opts.Hash = verifyHash(dstFS)
+	}
+	if opts.Verify {
+		if _, err := NewHash(opts.Hash); err != nil {
+			return err
+		}
+	}
+	target, err := journalTarget(ctx, opts.Journal, dstFS, srcNode, dst)
+	if err != nil {
+		return err
 	}
 	// If the filesystems are the same, use the filesystem's copy method
 	// we might get a better performance using the nateive copy method if exists
-	if srcFS == dstFS {
-		return srcFS.Copy(src, dst, recursive)
-	} else {
-		nodes, err := List(src, recursive)
+	// A filtered copy walks src to skip the excluded files instead
+	if srcFS == dstFS && opts.Filter == nil {
+		return copyNative(ctx, srcNode, dst, target, recursive, srcFS, opts)
+	}
+	if !srcNode.IsDir {
+		opts.progress(ProgressEvent{Type: ProgressQueued, Src: src, Dst: target, Size: srcNode.Size})
+		return copyNode(ctx, srcNode, target, srcFS, dstFS, opts)
+	}
+	if _, err := dstFS.MkDir(ctx, target); err != nil && !errors.Is(err, ErrAlreadyExists) {
+		return err
+	}
+	return transfer(ctx, src, target, recursive, srcFS, dstFS, opts)
+}
+
+/*
+copyNative copies src to dst with the copy method of fs, see nativeProgress for
+its progress. Only a file is retried, a directory copied again would go under
+the first copy.
+*/
+func copyNative(ctx context.Context, src Node, dst, target URI, recursive bool, fs FS, opts CopyOptions) error {
+	done, err := nativeProgress(ctx, src, target, recursive, fs, opts)
+	if err != nil {
+		return err
+	}
+	policy := opts.retry()
+	if src.IsDir {
+		policy = RetryPolicy{}
+	}
+	err = policy.Do(ctx, func() error {
+		return fs.Copy(ctx, src.URI, dst, recursive)
+	})
+	if err == nil && opts.Verify {
+		err = verifyTree(ctx, src.URI, target, recursive, fs, opts.Hash)
+	}
+	if err == nil && opts.Preserve {
+		err = preserveTree(ctx, src.URI, target, recursive, fs)
+	}
+	if err == nil && len(opts.Writer) > 0 {
+		err = metadataTree(ctx, src.URI, target, recursive, newWriterOptions(opts.Writer).Metadata, fs)
+	}
+	done(err)
+	return err
+}
+
+/*
+nativeProgress reports the files of src copied or moved to target by a native
+method of fs, which reads no byte: they are queued and started, then the
+returned func ends them with the error of the method, a copied file counting
+its whole size as read. The files of a directory are listed only if there is
+a ProgressFunc.
+*/
+func nativeProgress(ctx context.Context, src Node, target URI, recursive bool, fs FS, opts CopyOptions) (func(error), error) {
+	if opts.Progress == nil {
+		return func(error) {}, nil
+	}
+	events := []ProgressEvent{{Src: src.URI, Dst: target, Size: src.Size}}
+	if src.IsDir {
+		events = nil
+		err := fs.Walk(ctx, src.URI, WalkOptions{Recursive: recursive}, func(node Node) error {
+			if node.IsDir {
+				return nil
+			}
+			rel, err := src.URI.Rel(node.URI)
+			if err != nil {
+				return &PathError{Op: "copy", URI: node.URI, Err: err}
+			}
+			events = append(events, ProgressEvent{Src: node.URI, Dst: target.Join(rel), Size: node.Size})
+			return nil
+		})
 		if err != nil {
-			return err
+			return nil, err
 		}
-		for _, node := range nodes {
-			if !node.IsDir {
-				err := CopyFile(node.URI, dst, srcFS, dstFS)
-				if err != nil {
-					return err
-				}
-			}
+	}
+	for _, t := range []ProgressType{ProgressQueued, ProgressStart} {
+		for _, event := range events {
+			event.Type = t
+			opts.progress(event)
 		}
-		return nil
 	}
+	return func(err error) {
+		for _, event := range events {
+			if err != nil {
+				event.Type, event.Err = ProgressFailed, err
+				opts.progress(event)
+				continue
+			}
+			event.Type, event.Bytes = ProgressBytes, event.Size
+			opts.progress(event)
+			event.Type, event.Bytes = ProgressDone, 0
+			opts.progress(event)
+		}
+	}, nil
 }
 
-func CopyFile(src, dst URI, srcFS, dstFS FS) error {
-	srcFile, err := srcFS.Reader(src)
+/*
+CopyFile copies the file src into the directory dst, keeping its name.
+The options apply like for Copy, eg. WithPreserve keeps its attributes.
+*/
+func CopyFile(ctx context.Context, src, dst URI, srcFS, dstFS FS, opts ...CopyOption) error {
+	node, err := srcFS.Get(ctx, src)
 	if err != nil {
 		return err
 	}
-	// Modify the destination path to include the file name
-	dst.Path = path.Join(dst.Path, src.Name)
-	dstFile, err := dstFS.Writer(dst)
+	return copyNode(ctx, node, dst.Join(src.Name), srcFS, dstFS, newCopyOptions(opts))
+}
+
+/*
+copyFile copies the file src to the file dst, through stream, created with wopts.
+
+On failure the writer context is canceled before it is closed,
+so backends writing on close, like GCS, drop the partial content.
+*/
+func copyFile(ctx context.Context, src, dst URI, srcFS, dstFS FS, stream streamFunc, wopts ...WriterOption) error {
+	srcFile, err := srcFS.Reader(ctx, src)
 	if err != nil {
 		return err
 	}
-	_, err = io.Copy(dstFile, srcFile)
+	defer srcFile.Close()
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	dstFile, err := dstFS.Writer(wctx, dst, wopts...)
 	if err != nil {
 		return err
 	}
-	err = dstFile.Close()
+	_, err = CopyContext(ctx, dstFile, stream.wrap(ctx, srcFile))
 	if err != nil {
+		cancel()
+		dstFile.Close()
 		return err
 	}
-	err = srcFile.Close()
-	if err != nil {
-		return err
+	return dstFile.Close()
+}
+
+/*
+CopyContext behaves like io.Copy but checks ctx between chunks,
+returning ctx.Err() as soon as the context is done.
+*/
+func CopyContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	return io.Copy(dst, contextReader{ctx: ctx, r: src})
+}
+
+// contextReader is a reader that fails with the context error once ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
 	}
-	return nil
+	return cr.r.Read(p)
 }
 
 /*
 Move moves a file from one filesystem to another.
 
-Is implemented as a copy [src] [dst] followed by a delete [src].
+Within one file system implementing Mover, src is moved natively, eg.
+renamed, unless WithFilter is set or dst is an existing directory src is
+merged into. No byte is copied so nothing is verified. Otherwise it is a copy [src] [dst] followed by a delete [src],
+the source is kept if any file fails to copy or, WithVerify, to verify.
+The delete is retried with the policy of the copy, see Delete.
+WithFilter only moves the files it keeps, the rest of src stays.
 */
-func Move(src, dst URI, recursive bool) error {
-	err := Copy(src, dst, recursive)
+func (c *Client) Move(ctx context.Context, src, dst URI, recursive bool, opts ...CopyOption) error {
+	release, err := c.acquire(ctx)
 	if err != nil {
 		return err
 	}
-	srcFS := SchemeFS(src.Scheme)
-	err = connectFilesystems(srcFS)
+	defer release()
+	o := c.copyOptions(opts)
+	srcFS, err := c.FS(ctx, src.Scheme)
 	if err != nil {
 		return err
 	}
-	defer disconnectFilesystems(srcFS)
-	return srcFS.Delete(src, recursive)
+	dstFS, err := c.FS(ctx, dst.Scheme)
+	if err != nil {
+		return err
+	}
+	if mover, ok := srcFS.(Mover); ok && srcFS == dstFS && o.Filter == nil {
+		moved, err := c.moveNative(ctx, src, dst, recursive, mover, srcFS, o)
+		if moved || err != nil {
+			return err
+		}
+	}
+	err = c.copy(ctx, src, dst, recursive, o)
+	if err != nil {
+		return err
+	}
+	return retryDelete(ctx, o.retry(), srcFS, src, recursive, o.Filter)
+}
+
+/*
+moveNative moves src to dst with the Move method of fs, see nativeProgress for
+its progress. dst is resolved like copy does. Only a file is retried, a
+directory partially moved is not moved again.
+
+returns false without moving anything if src must be moved with a copy: a
+directory that is not moved recursively, or merged into an existing one.
+*/
+func (c *Client) moveNative(ctx context.Context, src, dst URI, recursive bool, mover Mover, fs FS, opts CopyOptions) (bool, error) {
+	var srcNode Node
+	err := c.opts.Retry.Do(ctx, func() (err error) {
+		srcNode, err = fs.Get(ctx, src)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	if srcNode.IsDir && !recursive {
+		return false, nil
+	}
+	target, err := copyTarget(ctx, fs, srcNode, dst)
+	if err != nil {
+		return false, err
+	}
+	if _, err := fs.Get(ctx, target); err == nil {
+		return false, nil
+	}
+	done, err := nativeProgress(ctx, srcNode, target, recursive, fs, opts)
+	if err != nil {
+		return false, err
+	}
+	policy := opts.retry()
+	if srcNode.IsDir {
+		policy = RetryPolicy{}
+	}
+	err = policy.Do(ctx, func() error {
+		return mover.Move(ctx, src, target)
+	})
+	done(err)
+	return true, err
 }
 
 /*
 Lists the contents of a directory. If recursive is true, lists recursively.
+A failed listing is retried from the start with the retry policy of the client.
 */
-func List(path URI, recursive bool) ([]Node, error) {
-	fs := SchemeFS(path.Scheme)
-	err := connectFilesystems(fs)
+func (c *Client) List(ctx context.Context, path URI, recursive bool) ([]Node, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return []Node{}, err
+	}
+	defer release()
+	fs, err := c.FS(ctx, path.Scheme)
 	if err != nil {
 		return []Node{}, err
 	}
-	defer disconnectFilesystems(fs)
-	return fs.List(path, recursive)
+	var nodes []Node
+	err = c.opts.Retry.Do(ctx, func() (err error) {
+		nodes, err = fs.List(ctx, path, recursive)
+		return err
+	})
+	return nodes, err
 }
 
-// Delete deletes a file or directory
-func Delete(path URI, recursive bool) error {
-	fs := SchemeFS(path.Scheme)
-	err := connectFilesystems(fs)
+/*
+Walk calls fn for every node under root as soon as it is listed,
+without building the whole listing in memory. opts.Filter skips nodes.
+
+A failed listing is retried with the retry policy of the client, going on
+after the last node fn handled. An error of fn is returned as is.
+*/
+func (c *Client) Walk(ctx context.Context, root URI, opts WalkOptions, fn WalkFunc) error {
+	release, err := c.acquire(ctx)
 	if err != nil {
 		return err
 	}
-	defer disconnectFilesystems(fs)
-	return fs.Delete(path, recursive)
+	defer release()
+	fs, err := c.FS(ctx, root.Scheme)
+	if err != nil {
+		return err
+	}
+	last := opts.PageToken
+	return c.opts.Retry.Do(ctx, func() error {
+		o := opts
+		o.PageToken = last
+		return o.Filter.Walk(ctx, fs, root, o, func(node Node) error {
+			err := fn(node)
+			switch {
+			case err == nil:
+				last = node.URI.Path
+			case errors.Is(err, SkipDir) || errors.Is(err, SkipAll):
+			default:
+				return &permanentError{err: err}
+			}
+			return err
+		})
+	})
 }
 
-// MkDir creates an empty directory
-func MkDir(path URI) (Node, error) {
-	fs := SchemeFS(path.Scheme)
-	err := connectFilesystems(fs)
+// Get returns the node of a file or directory
+func (c *Client) Get(ctx context.Context, path URI) (Node, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return Node{}, err
+	}
+	defer release()
+	fs, err := c.FS(ctx, path.Scheme)
 	if err != nil {
 		return Node{}, err
 	}
-	defer disconnectFilesystems(fs)
-	return fs.MkDir(path)
+	var node Node
+	err = c.opts.Retry.Do(ctx, func() (err error) {
+		node, err = fs.Get(ctx, path)
+		return err
+	})
+	return node, err
+}
+
+// DeleteOptions configures Client.Delete
+type DeleteOptions struct {
+	// Filter selects the files deleted from a directory
+	Filter *Filter
+}
+
+// DeleteOption sets a DeleteOptions field
+type DeleteOption func(*DeleteOptions)
+
+/*
+WithDeleteFilter only deletes the files of a directory that f keeps,
+the directories themselves are kept.
+*/
+func WithDeleteFilter(f *Filter) DeleteOption {
+	return func(o *DeleteOptions) { o.Filter = f }
+}
+
+func newDeleteOptions(opts []DeleteOption) DeleteOptions {
+	var o DeleteOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+/*
+Delete deletes a file or directory, see WithDeleteFilter.
+It is retried with the retry policy of the client, a retry that finds
+nothing to delete succeeds: the failed attempt may have deleted it.
+*/
+func (c *Client) Delete(ctx context.Context, path URI, recursive bool, opts ...DeleteOption) error {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	fs, err := c.FS(ctx, path.Scheme)
+	if err != nil {
+		return err
+	}
+	o := newDeleteOptions(opts)
+	return retryDelete(ctx, c.opts.Retry, fs, path, recursive, o.Filter)
+}
+
+/*
+retryDelete deletes uri like deleteFiltered, retried with policy. A retry
+that finds nothing to delete succeeds: the failed attempt may have deleted it.
+*/
+func retryDelete(ctx context.Context, policy RetryPolicy, fs FS, uri URI, recursive bool, f *Filter) error {
+	attempt := 0
+	return policy.Do(ctx, func() error {
+		attempt++
+		err := deleteFiltered(ctx, fs, uri, recursive, f)
+		if attempt > 1 && errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+/*
+deleteFiltered deletes uri, or with a filter and recursive the files
+under it the filter keeps, one by one.
+*/
+func deleteFiltered(ctx context.Context, fs FS, uri URI, recursive bool, f *Filter) error {
+	if f == nil || !recursive {
+		return fs.Delete(ctx, uri, recursive)
+	}
+	files, err := filteredFiles(ctx, fs, uri, f)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := fs.Delete(ctx, file.URI, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filteredFiles returns the files under uri f keeps, or uri itself if it is a file
+func filteredFiles(ctx context.Context, fs FS, uri URI, f *Filter) ([]Node, error) {
+	node, err := fs.Get(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if !node.IsDir {
+		return []Node{node}, nil
+	}
+	var files []Node
+	err = f.Walk(ctx, fs, uri, WalkOptions{Recursive: true}, func(node Node) error {
+		if !node.IsDir {
+			files = append(files, node)
+		}
+		return nil
+	})
+	return files, err
+}
+
+/*
+MkDir creates an empty directory.
+It is retried with the retry policy of the client, a retry that finds
+the directory succeeds: the failed attempt may have created it.
+*/
+func (c *Client) MkDir(ctx context.Context, path URI) (Node, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return Node{}, err
+	}
+	defer release()
+	fs, err := c.FS(ctx, path.Scheme)
+	if err != nil {
+		return Node{}, err
+	}
+	var node Node
+	attempt := 0
+	err = c.opts.Retry.Do(ctx, func() (err error) {
+		attempt++
+		node, err = fs.MkDir(ctx, path)
+		if attempt > 1 && errors.Is(err, ErrAlreadyExists) {
+			if node, err = fs.Get(ctx, path); err == nil && !node.IsDir {
+				err = &PathError{Op: "mkdir", URI: path, Err: ErrAlreadyExists}
+			}
+		}
+		return err
+	})
+	return node, err
+}
+
+// Copy copies src to dst with the DefaultClient, see Client.Copy
+func Copy(ctx context.Context, src, dst URI, recursive bool, opts ...CopyOption) error {
+	return DefaultClient.Copy(ctx, src, dst, recursive, opts...)
+}
+
+// Move moves src to dst with the DefaultClient, see Client.Move
+func Move(ctx context.Context, src, dst URI, recursive bool, opts ...CopyOption) error {
+	return DefaultClient.Move(ctx, src, dst, recursive, opts...)
+}
+
+// List lists a directory with the DefaultClient, see Client.List
+func List(ctx context.Context, path URI, recursive bool) ([]Node, error) {
+	return DefaultClient.List(ctx, path, recursive)
+}
+
+// Walk walks root with the DefaultClient, see Client.Walk
+func Walk(ctx context.Context, root URI, opts WalkOptions, fn WalkFunc) error {
+	return DefaultClient.Walk(ctx, root, opts, fn)
+}
+
+// Get returns the node of path with the DefaultClient, see Client.Get
+func Get(ctx context.Context, path URI) (Node, error) {
+	return DefaultClient.Get(ctx, path)
+}
+
+// Delete deletes a file or directory with the DefaultClient, see Client.Delete
+func Delete(ctx context.Context, path URI, recursive bool, opts ...DeleteOption) error {
+	return DefaultClient.Delete(ctx, path, recursive, opts...)
+}
+
+// MkDir creates a directory with the DefaultClient, see Client.MkDir
+func MkDir(ctx context.Context, path URI) (Node, error) {
+	return DefaultClient.MkDir(ctx, path)
 }
 
-func connectFilesystems(filesystems ...FS) error {
+func connectFilesystems(ctx context.Context, filesystems ...FS) error {
 	for _, fs := range filesystems {
-		if err := fs.Connect(); err != nil {
+		if err := fs.Connect(ctx); err != nil {
 			return errors.Join(ErrConnecting, err)
 		}
 	}
@@ -0,0 +1,213 @@
+package filesys
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func memURI(p string) URI { return NewURI(MemScheme, p) }
+
+// writeMemFile writes content to a MemFS file
+func writeMemFile(t *testing.T, m *MemFS, p, content string) {
+	t.Helper()
+	w, err := m.Writer(context.Background(), memURI(p))
+	assert.NoError(t, err)
+	_, err = io.WriteString(w, content)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+}
+
+func readMemFile(t *testing.T, m *MemFS, p string) string {
+	t.Helper()
+	r, err := m.Reader(context.Background(), memURI(p))
+	assert.NoError(t, err)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	return string(b)
+}
+
+func TestMemFSReadWrite(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemFS(0)
+
+	_, err := m.Writer(ctx, memURI("missing/a.txt"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = m.MkDir(ctx, memURI("dir/sub"))
+	assert.NoError(t, err)
+	_, err = m.MkDir(ctx, memURI("/dir/sub/"))
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	writeMemFile(t, m, "dir/sub/a.txt", "hello")
+	assert.Equal(t, "hello", readMemFile(t, m, "/dir/sub/a.txt"))
+	writeMemFile(t, m, "dir/sub/a.txt", "bye")
+	assert.Equal(t, "bye", readMemFile(t, m, "dir/sub/a.txt"))
+
+	node, err := m.Get(ctx, memURI("dir/sub/a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), node.Size)
+	assert.Equal(t, "text/plain; charset=utf-8", node.ContentType)
+	assert.Equal(t, "bfa99df33b137bc8fb5f5407d7e58da8", node.HexChecksum(MD5))
+	assert.False(t, node.ModTime.IsZero())
+
+	_, err = m.Writer(ctx, memURI("dir/sub"))
+	assert.ErrorIs(t, err, ErrIsDir)
+	_, err = m.Reader(ctx, memURI("dir"))
+	assert.ErrorIs(t, err, ErrIsDir)
+	_, err = m.Reader(ctx, memURI("dir/b.txt"))
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = m.MkDir(ctx, memURI("dir/sub/a.txt/x"))
+	assert.ErrorIs(t, err, ErrNotDir)
+
+	// Nothing is written until Close
+	w, err := m.Writer(ctx, memURI("dir/c.txt"))
+	assert.NoError(t, err)
+	_, err = io.WriteString(w, "pending")
+	assert.NoError(t, err)
+	_, err = m.Get(ctx, memURI("dir/c.txt"))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, w.Close())
+	assert.ErrorIs(t, w.Close(), ErrFileClose)
+}
+
+func TestMemFSDelete(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemFS(0)
+	_, err := m.MkDir(ctx, memURI("dir/sub"))
+	assert.NoError(t, err)
+	writeMemFile(t, m, "dir/sub/a.txt", "a")
+
+	assert.ErrorIs(t, m.Delete(ctx, memURI("dir"), false), ErrDirNotEmpty)
+	assert.ErrorIs(t, m.Delete(ctx, memURI("missing"), true), ErrNotFound)
+	assert.NoError(t, m.Delete(ctx, memURI("dir/sub/a.txt"), false))
+	assert.NoError(t, m.Delete(ctx, memURI("dir/sub"), false))
+	writeMemFile(t, m, "dir/b.txt", "b")
+	assert.NoError(t, m.Delete(ctx, memURI("dir"), true))
+	_, err = m.Get(ctx, memURI("dir"))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, int64(0), m.size)
+}
+
+func TestMemFSCopy(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemFS(0)
+	_, err := m.MkDir(ctx, memURI("src/sub"))
+	assert.NoError(t, err)
+	_, err = m.MkDir(ctx, memURI("dst"))
+	assert.NoError(t, err)
+	writeMemFile(t, m, "src/a.txt", "a")
+	writeMemFile(t, m, "src/sub/b.txt", "b")
+
+	assert.NoError(t, m.Copy(ctx, memURI("src/a.txt"), memURI("dst"), false))
+	assert.Equal(t, "a", readMemFile(t, m, "dst/a.txt"))
+	assert.NoError(t, m.Copy(ctx, memURI("src/sub/b.txt"), memURI("dst/a.txt"), false))
+	assert.Equal(t, "b", readMemFile(t, m, "dst/a.txt"))
+	assert.Equal(t, int64(3), m.size)
+	assert.ErrorIs(t, m.Copy(ctx, memURI("missing"), memURI("dst"), false), ErrNotFound)
+
+	assert.NoError(t, m.Copy(ctx, memURI("src"), memURI("copy"), true))
+	assert.Equal(t, "b", readMemFile(t, m, "copy/sub/b.txt"))
+	assert.ErrorIs(t, m.Copy(ctx, memURI("src"), memURI("src/sub"), true), ErrInvalidURI)
+
+	// Copies are independent
+	writeMemFile(t, m, "copy/sub/b.txt", "changed")
+	assert.Equal(t, "b", readMemFile(t, m, "src/sub/b.txt"))
+}
+
+func TestMemFSWalk(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemFS(0)
+	for _, dir := range []string{"b/d"} {
+		_, err := m.MkDir(ctx, memURI(dir))
+		assert.NoError(t, err)
+	}
+	for _, name := range []string{"a.txt", "b/c.txt", "b/d/e.txt", "b-f.txt"} {
+		writeMemFile(t, m, name, name)
+	}
+
+	walk := func(opts WalkOptions) []string {
+		var paths []string
+		err := m.Walk(ctx, memURI(""), opts, func(node Node) error {
+			paths = append(paths, node.URI.Path)
+			return nil
+		})
+		assert.NoError(t, err)
+		return paths
+	}
+	assert.Equal(t, []string{"a.txt", "b", "b-f.txt"}, walk(WalkOptions{}))
+	assert.Equal(t, []string{"a.txt", "b", "b/c.txt", "b/d", "b/d/e.txt", "b-f.txt"}, walk(WalkOptions{Recursive: true}))
+	assert.Equal(t, []string{"b/d", "b/d/e.txt", "b-f.txt"}, walk(WalkOptions{Recursive: true, PageToken: "b/c.txt"}))
+
+	nodes, err := m.List(ctx, memURI("b/c.txt"), false)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 1)
+	_, err = m.List(ctx, memURI("missing"), false)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemFSMaxSize(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemFS(10)
+	writeMemFile(t, m, "a.txt", "12345")
+
+	w, err := m.Writer(ctx, memURI("b.txt"))
+	assert.NoError(t, err)
+	_, err = io.WriteString(w, "123456789012")
+	assert.ErrorIs(t, err, ErrNoSpace)
+	assert.False(t, DefaultRetryPolicy.IsRetryable(err))
+
+	w, err = m.Writer(ctx, memURI("b.txt"))
+	assert.NoError(t, err)
+	_, err = io.WriteString(w, "123456")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, w.Close(), ErrNoSpace)
+
+	// Overwriting frees the previous content
+	writeMemFile(t, m, "a.txt", "1234567890")
+	assert.NoError(t, m.Delete(ctx, memURI("a.txt"), false))
+	writeMemFile(t, m, "b.txt", "1234567890")
+}
+
+func TestMemFSConcurrent(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemFS(0)
+	_, err := m.MkDir(ctx, memURI("dir"))
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("dir/%d.txt", i)
+			writeMemFile(t, m, name, strings.Repeat("x", i))
+			assert.Equal(t, strings.Repeat("x", i), readMemFile(t, m, name))
+			_, err := m.List(ctx, memURI("dir"), true)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	nodes, err := m.List(ctx, memURI("dir"), true)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 20)
+}
+
+func TestMemScheme(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+	uri, err := ParseURI("mem:///scratch")
+	assert.NoError(t, err)
+	_, err = client.MkDir(ctx, uri)
+	assert.NoError(t, err)
+	nodes, err := client.List(ctx, uri, true)
+	assert.NoError(t, err)
+	assert.Empty(t, nodes)
+}
@@ -0,0 +1,225 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"mime"
+	"net/http"
+	"path"
+)
+
+var ErrMetadataUnsupported = errors.New("metadata not supported")
+
+/*
+Metadata is the metadata a file system keeps with a file, set when it is
+written, see WriterOptions, or later with SetMetadata.
+*/
+type Metadata struct {
+	// ContentType is the MIME type of the content
+	ContentType string
+	// CacheControl is the Cache-Control header served with the file
+	CacheControl string
+	// ContentEncoding is the encoding of the content, eg. gzip
+	ContentEncoding string
+	// StorageClass is the storage class of an object, eg. NEARLINE on GCS
+	StorageClass string
+	// Custom is the user defined metadata
+	Custom map[string]string
+}
+
+// IsZero reports whether md has no field set
+func (md Metadata) IsZero() bool {
+	return md.ContentType == "" && md.CacheControl == "" && md.ContentEncoding == "" && md.StorageClass == "" && len(md.Custom) == 0
+}
+
+// merge sets the non empty fields of src on md, custom keys are added or replaced
+func (md *Metadata) merge(src Metadata) {
+	for _, f := range []struct{ dst, src *string }{
+		{&md.ContentType, &src.ContentType},
+		{&md.CacheControl, &src.CacheControl},
+		{&md.ContentEncoding, &src.ContentEncoding},
+		{&md.StorageClass, &src.StorageClass},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if len(src.Custom) > 0 {
+		// The map may be shared with a copy of md
+		custom := maps.Clone(md.Custom)
+		if custom == nil {
+			custom = map[string]string{}
+		}
+		maps.Copy(custom, src.Custom)
+		md.Custom = custom
+	}
+}
+
+/*
+MetadataStore is implemented by file systems that keep metadata with their files.
+
+GCS and MemFS keep every field. The local file system keeps the content
+type and the custom metadata in the user extended attributes of the file,
+on Linux only, the other fields are ignored.
+*/
+type MetadataStore interface {
+	// GetMetadata returns the metadata of the file uri
+	GetMetadata(ctx context.Context, uri URI) (Metadata, error)
+	// SetMetadata sets the non empty fields of md on the file uri, custom keys are added or replaced
+	SetMetadata(ctx context.Context, uri URI, md Metadata) error
+}
+
+/*
+WriterOptions configures the file created by FS.Writer.
+
+An empty ContentType is detected from the extension of the file name,
+or from its first bytes when the file system can sniff them.
+*/
+type WriterOptions struct {
+	Metadata
+}
+
+// WriterOption sets a WriterOptions field
+type WriterOption func(*WriterOptions)
+
+// WithContentType sets the MIME type of the file
+func WithContentType(contentType string) WriterOption {
+	return func(o *WriterOptions) { o.ContentType = contentType }
+}
+
+// WithCacheControl sets the Cache-Control header served with the file
+func WithCacheControl(cacheControl string) WriterOption {
+	return func(o *WriterOptions) { o.CacheControl = cacheControl }
+}
+
+// WithContentEncoding sets the encoding of the content, eg. gzip
+func WithContentEncoding(encoding string) WriterOption {
+	return func(o *WriterOptions) { o.ContentEncoding = encoding }
+}
+
+// WithStorageClass sets the storage class of the object, it replaces the storage_class query option of GCS
+func WithStorageClass(class string) WriterOption {
+	return func(o *WriterOptions) { o.StorageClass = class }
+}
+
+// WithCustomMetadata adds the custom metadata key
+func WithCustomMetadata(key, value string) WriterOption {
+	return func(o *WriterOptions) { o.merge(Metadata{Custom: map[string]string{key: value}}) }
+}
+
+// WithMetadata sets the non empty fields of md
+func WithMetadata(md Metadata) WriterOption {
+	return func(o *WriterOptions) { o.merge(md) }
+}
+
+func newWriterOptions(opts []WriterOption) WriterOptions {
+	var o WriterOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+/*
+WithWriterOptions sets the options of the writers of the copied files.
+
+A native copy within one file system keeps the metadata of the source,
+the metadata set by opts is then set on the copies of a MetadataStore.
+*/
+func WithWriterOptions(opts ...WriterOption) CopyOption {
+	return func(o *CopyOptions) { o.Writer = append(o.Writer, opts...) }
+}
+
+/*
+detectContentType returns the MIME type of the file name from its
+extension, or sniffed from head, the first bytes of its content.
+
+returns "" if the extension is unknown and head is nil
+*/
+func detectContentType(name string, head []byte) string {
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType
+	}
+	if head == nil {
+		return ""
+	}
+	return http.DetectContentType(head)
+}
+
+/*
+GetMetadata returns the metadata of a file.
+
+returns a *PathError wrapping ErrMetadataUnsupported if its file system is not a MetadataStore
+*/
+func (c *Client) GetMetadata(ctx context.Context, uri URI) (Metadata, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer release()
+	store, err := c.metadataStore(ctx, "getmetadata", uri)
+	if err != nil {
+		return Metadata{}, err
+	}
+	var md Metadata
+	err = c.opts.Retry.Do(ctx, func() (err error) {
+		md, err = store.GetMetadata(ctx, uri)
+		return err
+	})
+	return md, err
+}
+
+/*
+SetMetadata sets the non empty fields of md on a file, custom keys are added or replaced.
+
+returns a *PathError wrapping ErrMetadataUnsupported if its file system is not a MetadataStore
+*/
+func (c *Client) SetMetadata(ctx context.Context, uri URI, md Metadata) error {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	store, err := c.metadataStore(ctx, "setmetadata", uri)
+	if err != nil {
+		return err
+	}
+	return c.opts.Retry.Do(ctx, func() error {
+		return store.SetMetadata(ctx, uri, md)
+	})
+}
+
+// metadataStore returns the file system of uri if it is a MetadataStore
+func (c *Client) metadataStore(ctx context.Context, op string, uri URI) (MetadataStore, error) {
+	fs, err := c.FS(ctx, uri.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	store, ok := fs.(MetadataStore)
+	if !ok {
+		return nil, &PathError{Op: op, URI: uri, Err: ErrMetadataUnsupported}
+	}
+	return store, nil
+}
+
+/*
+metadataTree sets md on every file under src copied under dst, used after
+the native copy of a file system, like preserveTree.
+*/
+func metadataTree(ctx context.Context, src, dst URI, recursive bool, md Metadata, fs FS) error {
+	store, ok := fs.(MetadataStore)
+	if !ok || md.IsZero() {
+		return nil
+	}
+	return fs.Walk(ctx, src, WalkOptions{Recursive: recursive}, func(node Node) error {
+		if node.IsDir {
+			return nil
+		}
+		target := dst
+		if rel, err := src.Rel(node.URI); err == nil && rel != "." {
+			target = dst.Join(rel)
+		}
+		return store.SetMetadata(ctx, target, md)
+	})
+}
@@ -0,0 +1,122 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemMetadata(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemFS(0)
+	write := func(p, content string, opts ...WriterOption) {
+		t.Helper()
+		w, err := m.Writer(ctx, memURI(p), opts...)
+		assert.NoError(t, err)
+		_, err = io.WriteString(w, content)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+	}
+	write("/a.css", "body {}", WithCacheControl("no-cache"), WithContentEncoding("identity"),
+		WithStorageClass("COLDLINE"), WithCustomMetadata("k1", "v1"), WithCustomMetadata("k2", "v2"))
+	md, err := m.GetMetadata(ctx, memURI("/a.css"))
+	assert.NoError(t, err)
+	assert.Equal(t, Metadata{
+		ContentType:     "text/css; charset=utf-8",
+		CacheControl:    "no-cache",
+		ContentEncoding: "identity",
+		StorageClass:    "COLDLINE",
+		Custom:          map[string]string{"k1": "v1", "k2": "v2"},
+	}, md)
+
+	// The content type is given, or detected from the extension then the content
+	write("/b.css", "body {}", WithContentType("text/plain"))
+	assert.Equal(t, "text/plain", mustGet(t, m, "/b.css").ContentType)
+	write("/page", "<html><body></body></html>")
+	assert.Equal(t, "text/html; charset=utf-8", mustGet(t, m, "/page").ContentType)
+	write("/data", "\x00\x01\x02")
+	assert.Equal(t, "application/octet-stream", mustGet(t, m, "/data").ContentType)
+
+	// Set fields replace the others, custom keys are merged
+	assert.NoError(t, m.SetMetadata(ctx, memURI("/a.css"), Metadata{CacheControl: "max-age=60", Custom: map[string]string{"k2": "new"}}))
+	md, err = m.GetMetadata(ctx, memURI("/a.css"))
+	assert.NoError(t, err)
+	assert.Equal(t, "max-age=60", md.CacheControl)
+	assert.Equal(t, "identity", md.ContentEncoding)
+	assert.Equal(t, map[string]string{"k1": "v1", "k2": "new"}, md.Custom)
+	_, err = m.GetMetadata(ctx, memURI("/missing"))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCopyWriterOptions(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644))
+	local := NewURI(LocalScheme, filepath.Join(dir, "a.txt"))
+	opts := WithWriterOptions(WithCacheControl("no-store"), WithCustomMetadata("origin", "local"))
+
+	// Streamed to another file system, with or without the journal
+	assert.NoError(t, client.Copy(ctx, local, memURI("/a.txt"), false, opts))
+	j, err := CreateJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+	assert.NoError(t, err)
+	defer j.Close()
+	assert.NoError(t, client.Copy(ctx, local, memURI("/journaled.txt"), false, opts, WithJournal(j), WithVerify("")))
+	// Natively copied, then set
+	assert.NoError(t, client.Copy(ctx, memURI("/a.txt"), memURI("/native.txt"), false, WithWriterOptions(WithCustomMetadata("copy", "native"))))
+	for _, p := range []string{"/a.txt", "/journaled.txt"} {
+		md, err := client.GetMetadata(ctx, memURI(p))
+		assert.NoError(t, err)
+		assert.Equal(t, Metadata{ContentType: "text/plain; charset=utf-8", CacheControl: "no-store", Custom: map[string]string{"origin": "local"}}, md, p)
+	}
+	md, err := client.GetMetadata(ctx, memURI("/native.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"origin": "local", "copy": "native"}, md.Custom)
+	assert.NoError(t, client.SetMetadata(ctx, memURI("/native.txt"), Metadata{ContentType: "text/csv"}))
+	node, err := client.Get(ctx, memURI("/native.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "text/csv", node.ContentType)
+}
+
+func TestLocalMetadata(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("extended attributes are only supported on Linux")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("a"), 0644))
+	uri := NewURI(LocalScheme, path)
+	local := NewLocalFS()
+	err := local.SetMetadata(ctx, uri, Metadata{ContentType: "text/markdown", CacheControl: "ignored", Custom: map[string]string{"k": "v"}})
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("no extended attributes on", dir)
+	}
+	assert.NoError(t, err)
+	md, err := local.GetMetadata(ctx, uri)
+	assert.NoError(t, err)
+	assert.Equal(t, Metadata{ContentType: "text/markdown", Custom: map[string]string{"k": "v"}}, md)
+
+	// The attributes are set on the temporary file, then renamed
+	for _, l := range []*LocalFS{local, {InPlace: true}} {
+		w, err := l.Writer(ctx, NewURI(LocalScheme, filepath.Join(dir, "b")), WithCustomMetadata("writer", "yes"))
+		assert.NoError(t, err)
+		_, err = io.WriteString(w, "%PDF-1.7")
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+		md, err = local.GetMetadata(ctx, NewURI(LocalScheme, filepath.Join(dir, "b")))
+		assert.NoError(t, err)
+		assert.Equal(t, Metadata{ContentType: "application/pdf", Custom: map[string]string{"writer": "yes"}}, md)
+	}
+
+	_, err = local.GetMetadata(ctx, NewURI(LocalScheme, filepath.Join(dir, "missing")))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, local.SetMetadata(ctx, NewURI(LocalScheme, filepath.Join(dir, "missing")), md), ErrNotFound)
+}
@@ -0,0 +1,269 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+)
+
+// PlanOp is a change an operation would make to a file system
+type PlanOp string
+
+const (
+	ActionCreate    PlanOp = "create"
+	ActionOverwrite PlanOp = "overwrite"
+	ActionDelete    PlanOp = "delete"
+	ActionMkDir     PlanOp = "mkdir"
+)
+
+// PlanAction is one change of a Plan, Src is only set for created and overwritten files
+type PlanAction struct {
+	Op    PlanOp `json:"op"`
+	URI   string `json:"uri"`
+	Src   string `json:"src,omitempty"`
+	Size  int64  `json:"size"`
+	IsDir bool   `json:"dir,omitempty"`
+}
+
+/*
+Plan lists every change an operation would make, computed without
+modifying any file system.
+*/
+type Plan struct {
+	Actions    []PlanAction `json:"actions"`
+	Creates    int          `json:"creates"`
+	Overwrites int          `json:"overwrites"`
+	Deletes    int          `json:"deletes"`
+	MkDirs     int          `json:"mkdirs"`
+	// Bytes is the size of the created and overwritten files
+	Bytes int64 `json:"bytes"`
+	// DeletedBytes is the size of the deleted files
+	DeletedBytes int64 `json:"deleted_bytes"`
+}
+
+func (p *Plan) add(action PlanAction) {
+	p.Actions = append(p.Actions, action)
+	switch action.Op {
+	case ActionCreate:
+		p.Creates++
+		p.Bytes += action.Size
+	case ActionOverwrite:
+		p.Overwrites++
+		p.Bytes += action.Size
+	case ActionDelete:
+		p.Deletes++
+		p.DeletedBytes += action.Size
+	case ActionMkDir:
+		p.MkDirs++
+	}
+}
+
+// Merge adds the actions and totals of other to p
+func (p *Plan) Merge(other Plan) {
+	for _, action := range other.Actions {
+		p.add(action)
+	}
+}
+
+/*
+PlanCopy returns the changes Copy would make, with the same destination, recursion rules
+and filter, the other options do not change the plan.
+
+returns the errors Copy would fail with before copying anything,
+eg. ErrNotFound if src does not exist
+*/
+func (c *Client) PlanCopy(ctx context.Context, src, dst URI, recursive bool, opts ...CopyOption) (Plan, error) {
+	var plan Plan
+	o := newCopyOptions(opts)
+	err := c.plan(ctx, func(fs func(scheme string) (FS, error)) error {
+		return planCopy(ctx, &plan, fs, src, dst, recursive, o.Filter)
+	})
+	return plan, err
+}
+
+// PlanMove returns the changes Move would make: the copy then the deletion of src
+func (c *Client) PlanMove(ctx context.Context, src, dst URI, recursive bool, opts ...CopyOption) (Plan, error) {
+	var plan Plan
+	o := newCopyOptions(opts)
+	err := c.plan(ctx, func(fs func(scheme string) (FS, error)) error {
+		if err := planCopy(ctx, &plan, fs, src, dst, recursive, o.Filter); err != nil {
+			return err
+		}
+		return planDelete(ctx, &plan, fs, src, recursive, o.Filter)
+	})
+	return plan, err
+}
+
+/*
+PlanDelete returns the files and directories Delete would remove.
+
+returns:
+  - ErrNotFound if uri does not exist
+  - ErrDirNotEmpty if uri is a directory with content and recursive is false
+*/
+func (c *Client) PlanDelete(ctx context.Context, uri URI, recursive bool, opts ...DeleteOption) (Plan, error) {
+	var plan Plan
+	o := newDeleteOptions(opts)
+	err := c.plan(ctx, func(fs func(scheme string) (FS, error)) error {
+		return planDelete(ctx, &plan, fs, uri, recursive, o.Filter)
+	})
+	return plan, err
+}
+
+/*
+PlanMkDir returns the directories MkDir would create, uri and its missing parents.
+
+returns ErrAlreadyExists if uri already exists
+*/
+func (c *Client) PlanMkDir(ctx context.Context, uri URI) (Plan, error) {
+	var plan Plan
+	err := c.plan(ctx, func(fs func(scheme string) (FS, error)) error {
+		return planMkDir(ctx, &plan, fs, uri)
+	})
+	return plan, err
+}
+
+// plan runs fn with an operation slot of the client
+func (c *Client) plan(ctx context.Context, fn func(fs func(scheme string) (FS, error)) error) error {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn(func(scheme string) (FS, error) { return c.FS(ctx, scheme) })
+}
+
+func planCopy(ctx context.Context, plan *Plan, fsOf func(string) (FS, error), src, dst URI, recursive bool, f *Filter) error {
+	srcFS, err := fsOf(src.Scheme)
+	if err != nil {
+		return err
+	}
+	dstFS, err := fsOf(dst.Scheme)
+	if err != nil {
+		return err
+	}
+	srcNode, err := srcFS.Get(ctx, src)
+	if err != nil {
+		return err
+	}
+	target, err := copyTarget(ctx, dstFS, srcNode, dst)
+	if err != nil {
+		return err
+	}
+	if !srcNode.IsDir {
+		op := ActionCreate
+		if _, err := dstFS.Get(ctx, target); err == nil {
+			op = ActionOverwrite
+		} else if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		plan.add(PlanAction{Op: op, URI: target.String(), Src: src.String(), Size: srcNode.Size})
+		return nil
+	}
+	existing, err := syncTree(ctx, dstFS, target, nil)
+	if errors.Is(err, ErrNotFound) {
+		plan.add(PlanAction{Op: ActionMkDir, URI: target.String(), IsDir: true})
+	} else if err != nil {
+		return err
+	}
+	return f.Walk(ctx, srcFS, src, WalkOptions{Recursive: recursive}, func(node Node) error {
+		rel, err := src.Rel(node.URI)
+		if err != nil {
+			return &PathError{Op: "copy", URI: node.URI, Err: err}
+		}
+		to := target.Join(rel)
+		old, exists := existing[rel]
+		switch {
+		case node.IsDir && !exists:
+			plan.add(PlanAction{Op: ActionMkDir, URI: to.String(), IsDir: true})
+		case node.IsDir:
+		case exists && old.IsDir:
+			return &PathError{Op: "copy", URI: to, Err: ErrIsDir}
+		case exists:
+			plan.add(PlanAction{Op: ActionOverwrite, URI: to.String(), Src: node.URI.String(), Size: node.Size})
+		default:
+			plan.add(PlanAction{Op: ActionCreate, URI: to.String(), Src: node.URI.String(), Size: node.Size})
+		}
+		return nil
+	})
+}
+
+func planDelete(ctx context.Context, plan *Plan, fsOf func(string) (FS, error), uri URI, recursive bool, f *Filter) error {
+	fs, err := fsOf(uri.Scheme)
+	if err != nil {
+		return err
+	}
+	if f != nil && recursive {
+		files, err := filteredFiles(ctx, fs, uri, f)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			plan.add(PlanAction{Op: ActionDelete, URI: file.URI.String(), Size: file.Size})
+		}
+		return nil
+	}
+	node, err := fs.Get(ctx, uri)
+	if err != nil {
+		return err
+	}
+	if node.IsDir {
+		err := fs.Walk(ctx, uri, WalkOptions{Recursive: true}, func(child Node) error {
+			if !recursive {
+				return &PathError{Op: "delete", URI: uri, Err: ErrDirNotEmpty}
+			}
+			plan.add(PlanAction{Op: ActionDelete, URI: child.URI.String(), Size: child.Size, IsDir: child.IsDir})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	plan.add(PlanAction{Op: ActionDelete, URI: uri.String(), Size: node.Size, IsDir: node.IsDir})
+	return nil
+}
+
+func planMkDir(ctx context.Context, plan *Plan, fsOf func(string) (FS, error), uri URI) error {
+	fs, err := fsOf(uri.Scheme)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.Get(ctx, uri); err == nil {
+		return &PathError{Op: "mkdir", URI: uri, Err: ErrAlreadyExists}
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	// Parents first, up to the first existing one
+	dirs := []URI{uri}
+	for child, dir := uri, uri.Parent(); dir.Path != child.Path && !dir.IsDirLike(); child, dir = dir, dir.Parent() {
+		if _, err := fs.Get(ctx, dir); err == nil {
+			break
+		} else if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		dirs = append(dirs, dir)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		plan.add(PlanAction{Op: ActionMkDir, URI: dirs[i].String(), IsDir: true})
+	}
+	return nil
+}
+
+// PlanCopy plans a copy with the DefaultClient, see Client.PlanCopy
+func PlanCopy(ctx context.Context, src, dst URI, recursive bool, opts ...CopyOption) (Plan, error) {
+	return DefaultClient.PlanCopy(ctx, src, dst, recursive, opts...)
+}
+
+// PlanMove plans a move with the DefaultClient, see Client.PlanMove
+func PlanMove(ctx context.Context, src, dst URI, recursive bool, opts ...CopyOption) (Plan, error) {
+	return DefaultClient.PlanMove(ctx, src, dst, recursive, opts...)
+}
+
+// PlanDelete plans a delete with the DefaultClient, see Client.PlanDelete
+func PlanDelete(ctx context.Context, uri URI, recursive bool, opts ...DeleteOption) (Plan, error) {
+	return DefaultClient.PlanDelete(ctx, uri, recursive, opts...)
+}
+
+// PlanMkDir plans a mkdir with the DefaultClient, see Client.PlanMkDir
+func PlanMkDir(ctx context.Context, uri URI) (Plan, error) {
+	return DefaultClient.PlanMkDir(ctx, uri)
+}
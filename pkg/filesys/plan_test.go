@@ -0,0 +1,122 @@
+package filesys
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlan(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+	fs, err := client.FS(ctx, MemScheme)
+	assert.NoError(t, err)
+	m := fs.(*MemFS)
+	for _, dir := range []string{"/src/sub", "/dst/src"} {
+		_, err = m.MkDir(ctx, memURI(dir))
+		assert.NoError(t, err)
+	}
+	writeMemFile(t, m, "/src/a.txt", "aaa")
+	writeMemFile(t, m, "/src/sub/b.txt", "bb")
+	writeMemFile(t, m, "/dst/src/a.txt", "old")
+
+	plan, err := client.PlanCopy(ctx, memURI("/src"), memURI("/dst"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, []PlanAction{
+		{Op: ActionOverwrite, URI: "mem:///dst/src/a.txt", Src: "mem:///src/a.txt", Size: 3},
+		{Op: ActionMkDir, URI: "mem:///dst/src/sub", IsDir: true},
+		{Op: ActionCreate, URI: "mem:///dst/src/sub/b.txt", Src: "mem:///src/sub/b.txt", Size: 2},
+	}, plan.Actions)
+	assert.Equal(t, 1, plan.Creates)
+	assert.Equal(t, 1, plan.Overwrites)
+	assert.Equal(t, int64(5), plan.Bytes)
+
+	plan, err = client.PlanCopy(ctx, memURI("/src"), memURI("/new"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, PlanAction{Op: ActionMkDir, URI: "mem:///new", IsDir: true}, plan.Actions[0])
+	assert.Equal(t, 2, plan.Creates)
+
+	// A file onto an existing file is overwritten, like the copy does
+	plan, err = client.PlanCopy(ctx, memURI("/src/a.txt"), memURI("/dst/src/a.txt"), false)
+	assert.NoError(t, err)
+	assert.Equal(t, []PlanAction{{Op: ActionOverwrite, URI: "mem:///dst/src/a.txt", Src: "mem:///src/a.txt", Size: 3}}, plan.Actions)
+	plan, err = client.PlanCopy(ctx, memURI("/src/a.txt"), memURI("/dst/new.txt"), false)
+	assert.NoError(t, err)
+	assert.Equal(t, []PlanAction{{Op: ActionCreate, URI: "mem:///dst/new.txt", Src: "mem:///src/a.txt", Size: 3}}, plan.Actions)
+
+	plan, err = client.PlanMove(ctx, memURI("/src/sub"), memURI("/moved"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, plan.Creates)
+	assert.Equal(t, 2, plan.Deletes)
+	assert.Equal(t, int64(2), plan.DeletedBytes)
+
+	plan, err = client.PlanDelete(ctx, memURI("/src"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, plan.Deletes)
+	assert.Equal(t, int64(5), plan.DeletedBytes)
+	_, err = client.PlanDelete(ctx, memURI("/src"), false)
+	assert.ErrorIs(t, err, ErrDirNotEmpty)
+	_, err = client.PlanDelete(ctx, memURI("/missing"), true)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	plan, err = client.PlanMkDir(ctx, memURI("/src/x/y"))
+	assert.NoError(t, err)
+	assert.Equal(t, []PlanAction{
+		{Op: ActionMkDir, URI: "mem:///src/x", IsDir: true},
+		{Op: ActionMkDir, URI: "mem:///src/x/y", IsDir: true},
+	}, plan.Actions)
+	_, err = client.PlanMkDir(ctx, memURI("/src"))
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	// Nothing changed
+	assert.Equal(t, "aaa", readMemFile(t, m, "/src/a.txt"))
+	assert.Equal(t, "old", readMemFile(t, m, "/dst/src/a.txt"))
+	_, err = m.Get(ctx, memURI("/new"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	b, err := json.Marshal(plan)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"mkdirs":2`)
+}
+
+// TestPlanCopyNotRecursive checks the plan lists what a copy without recursive writes
+func TestPlanCopyNotRecursive(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+	fs, err := client.FS(ctx, MemScheme)
+	assert.NoError(t, err)
+	m := fs.(*MemFS)
+	_, err = m.MkDir(ctx, memURI("/src/sub"))
+	assert.NoError(t, err)
+	writeMemFile(t, m, "/src/a.txt", "aaa")
+	writeMemFile(t, m, "/src/sub/b.txt", "bb")
+
+	for name, dst := range map[string]URI{
+		"native":   memURI("/dst"),
+		"transfer": NewURI(LocalScheme, t.TempDir()).Join("dst"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			plan, err := client.PlanCopy(ctx, memURI("/src"), dst, false)
+			assert.NoError(t, err)
+			var planned []string
+			for _, action := range plan.Actions {
+				planned = append(planned, action.URI)
+			}
+			assert.NoError(t, client.Copy(ctx, memURI("/src"), dst, false))
+			dstFS, err := client.FS(ctx, dst.Scheme)
+			assert.NoError(t, err)
+			nodes, err := dstFS.List(ctx, dst, true)
+			assert.NoError(t, err)
+			written := []string{dst.String()}
+			for _, node := range nodes {
+				written = append(written, node.URI.String())
+			}
+			assert.ElementsMatch(t, written, planned)
+			assert.Equal(t, 1, plan.Creates)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package filesys
+
+import (
+	"context"
+	"io/fs"
+	"time"
+)
+
+// Owner is the numeric user and group owning a file
+type Owner struct {
+	UID int
+	GID int
+}
+
+/*
+FileAttrs are the attributes of a file kept by WithPreserve.
+Zero fields are unknown and left unchanged.
+*/
+type FileAttrs struct {
+	ModTime time.Time
+	// Mode holds the permission bits
+	Mode  fs.FileMode
+	Owner *Owner
+}
+
+// Attrs returns the attributes of the node WithPreserve keeps
+func (n Node) Attrs() FileAttrs {
+	return FileAttrs{ModTime: n.ModTime, Mode: n.Mode.Perm(), Owner: n.Owner}
+}
+
+/*
+AttrSetter is implemented by file systems that can set the attributes
+of a file, WithPreserve sets the ones of the source on the copied files.
+*/
+type AttrSetter interface {
+	SetAttrs(ctx context.Context, uri URI, attrs FileAttrs) error
+}
+
+/*
+WithPreserve keeps the modification time, permissions and owner of the
+copied files, on destinations implementing AttrSetter. Others ignore it.
+
+The local file system sets them on the file, the owner only if the
+process is allowed to. GCS stores them in the object custom metadata,
+like gsutil does, and reports them back as the attributes of the object.
+*/
+func WithPreserve() CopyOption {
+	return func(o *CopyOptions) { o.Preserve = true }
+}
+
+// preserveAttrs sets the attributes of the file src on the file dst, if fs can
+func preserveAttrs(ctx context.Context, src Node, dst URI, fs FS) error {
+	setter, ok := fs.(AttrSetter)
+	if !ok {
+		return nil
+	}
+	return setter.SetAttrs(ctx, dst, src.Attrs())
+}
+
+/*
+preserveTree sets the attributes of every file under src on its copy
+under dst, used after the native copy of a file system.
+*/
+func preserveTree(ctx context.Context, src, dst URI, recursive bool, fs FS) error {
+	if _, ok := fs.(AttrSetter); !ok {
+		return nil
+	}
+	return fs.Walk(ctx, src, WalkOptions{Recursive: recursive}, func(node Node) error {
+		if node.IsDir {
+			return nil
+		}
+		target := dst
+		if rel, err := src.Rel(node.URI); err == nil && rel != "." {
+			target = dst.Join(rel)
+		}
+		return preserveAttrs(ctx, node, target, fs)
+	})
+}
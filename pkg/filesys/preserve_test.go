@@ -0,0 +1,106 @@
+package filesys
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreserve(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+	dir := t.TempDir()
+	mtime := time.Date(2020, 5, 6, 7, 8, 9, 0, time.Local)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "src", "sub"), 0755))
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		path := filepath.Join(dir, "src", name)
+		assert.NoError(t, os.WriteFile(path, []byte(name), 0600))
+		assert.NoError(t, os.Chmod(path, 0640))
+		assert.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	local := func(p string) URI { return NewURI(LocalScheme, filepath.Join(dir, p)) }
+	assertAttrs := func(p string) {
+		t.Helper()
+		info, err := os.Stat(filepath.Join(dir, p))
+		if assert.NoError(t, err) {
+			assert.True(t, mtime.Equal(info.ModTime()), p)
+			assert.Equal(t, fs.FileMode(0640), info.Mode().Perm(), p)
+		}
+	}
+
+	// The native copy of the local file system
+	assert.NoError(t, client.Copy(ctx, local("src"), local("copy"), true, WithPreserve()))
+	assertAttrs("copy/a.txt")
+	assertAttrs("copy/sub/b.txt")
+
+	// A round trip through another file system
+	assert.NoError(t, client.Copy(ctx, local("src"), memURI("/src"), true, WithPreserve()))
+	assert.NoError(t, client.Copy(ctx, memURI("/src"), local("back"), true, WithPreserve()))
+	assertAttrs("back/sub/b.txt")
+
+	// Without the option, the copy is a new file
+	assert.NoError(t, client.Copy(ctx, local("src/a.txt"), local("plain.txt"), false))
+	info, err := os.Stat(filepath.Join(dir, "plain.txt"))
+	assert.NoError(t, err)
+	assert.False(t, mtime.Equal(info.ModTime()))
+
+	assert.NoError(t, CopyLocalFile(ctx, local("src/a.txt"), local("single.txt"), WithPreserve()))
+	assertAttrs("single.txt")
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "into"), 0755))
+	assert.NoError(t, CopyFile(ctx, local("src/a.txt"), local("into"), NewLocalFS(), NewLocalFS(), WithPreserve()))
+	assertAttrs("into/a.txt")
+}
+
+func TestLocalOwner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no numeric owner")
+	}
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "a.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("a"), 0644))
+	uri := NewURI(LocalScheme, path)
+	node, err := NewLocalFS().Get(ctx, uri)
+	assert.NoError(t, err)
+	if assert.NotNil(t, node.Owner) {
+		assert.Equal(t, os.Getuid(), node.Owner.UID)
+		assert.Equal(t, os.Getgid(), node.Owner.GID)
+	}
+
+	// Setting the current owner always works
+	assert.NoError(t, NewLocalFS().SetAttrs(ctx, uri, FileAttrs{Owner: node.Owner}))
+	err = NewLocalFS().SetAttrs(ctx, NewURI(LocalScheme, path+".missing"), FileAttrs{Mode: 0600})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGCPFileAttrs(t *testing.T) {
+	mtime := time.Unix(1700000000, 0)
+	attrs := FileAttrs{ModTime: mtime.Add(500 * time.Millisecond), Mode: 0750, Owner: &Owner{UID: 1000, GID: 100}}
+	metadata := gcpFileAttrsMetadata(attrs)
+	assert.Equal(t, map[string]string{
+		"goog-reserved-file-mtime": "1700000000",
+		"goog-reserved-posix-mode": "750",
+		"goog-reserved-posix-uid":  "1000",
+		"goog-reserved-posix-gid":  "100",
+	}, metadata)
+	assert.Empty(t, gcpFileAttrsMetadata(FileAttrs{}))
+
+	// The stored attributes replace the ones of the object
+	node := NewNode(NewURI(GCPBucketScheme, "bucket/a.txt"), false)
+	node.ModTime = time.Now()
+	setGCPFileAttrs(&node, metadata)
+	assert.True(t, mtime.Equal(node.ModTime))
+	assert.Equal(t, fs.FileMode(0750), node.Mode)
+	assert.Equal(t, &Owner{UID: 1000, GID: 100}, node.Owner)
+
+	node = NewNode(NewURI(GCPBucketScheme, "bucket/a.txt"), false)
+	setGCPFileAttrs(&node, map[string]string{gcpModeKey: "not octal"})
+	assert.Equal(t, fs.FileMode(0), node.Mode)
+	assert.Nil(t, node.Owner)
+}
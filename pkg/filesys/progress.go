@@ -0,0 +1,112 @@
+package filesys
+
+import (
+	"context"
+	"io"
+)
+
+// ProgressType is the kind of a ProgressEvent
+type ProgressType string
+
+const (
+	// ProgressQueued is sent when a file to copy is listed, before its copy starts
+	ProgressQueued ProgressType = "queued"
+	ProgressStart  ProgressType = "start"
+	// ProgressBytes is sent as the content of a file is read from its source
+	ProgressBytes  ProgressType = "bytes"
+	ProgressDone   ProgressType = "done"
+	ProgressFailed ProgressType = "failed"
+	// ProgressRetry is sent when a failed copy is tried again, its bytes are read again
+	ProgressRetry ProgressType = "retry"
+)
+
+// ProgressEvent reports a step of the copy of a file
+type ProgressEvent struct {
+	Type ProgressType
+	Src  URI
+	Dst  URI
+	// Size is the size of the source file
+	Size int64
+	// Bytes is the number of bytes read since the previous event of the file
+	Bytes int64
+	// Err is the error of a ProgressFailed or ProgressRetry event
+	Err error
+}
+
+/*
+ProgressFunc receives the progress events of a copy.
+
+It is called by every transfer worker, so it must be safe for concurrent
+use, and it should return quickly as the copy waits for it.
+*/
+type ProgressFunc func(ProgressEvent)
+
+/*
+WithProgress calls fn with the progress of every copied file, from
+Copy, Move and Sync. A copy or move within one file system with its
+native method reports the whole size of each file in one ProgressBytes
+event, once the method succeeded.
+*/
+func WithProgress(fn ProgressFunc) CopyOption {
+	return func(o *CopyOptions) { o.Progress = fn }
+}
+
+/*
+WithProgressChan sends the progress events to ch, see WithProgress.
+The copy blocks while ch is full.
+*/
+func WithProgressChan(ch chan<- ProgressEvent) CopyOption {
+	return WithProgress(func(e ProgressEvent) { ch <- e })
+}
+
+// progress sends the event e if there is a ProgressFunc
+func (o CopyOptions) progress(e ProgressEvent) {
+	if o.Progress != nil {
+		o.Progress(e)
+	}
+}
+
+/*
+streamFunc wraps the source stream of a file copy, to report its progress
+and limit its bandwidth. A nil streamFunc leaves the stream unchanged.
+*/
+type streamFunc func(ctx context.Context, r io.Reader) io.Reader
+
+func (s streamFunc) wrap(ctx context.Context, r io.Reader) io.Reader {
+	if s == nil {
+		return r
+	}
+	return s(ctx, r)
+}
+
+// stream returns the streamFunc of the copy of src to dst
+func (o CopyOptions) stream(src Node, dst URI) streamFunc {
+	if o.Progress == nil && o.Bandwidth == nil {
+		return nil
+	}
+	return func(ctx context.Context, r io.Reader) io.Reader {
+		if o.Bandwidth != nil {
+			r = o.Bandwidth.Reader(ctx, r)
+		}
+		if o.Progress != nil {
+			r = &progressReader{r: r, fn: func(n int) {
+				o.Progress(ProgressEvent{Type: ProgressBytes, Src: src.URI, Dst: dst, Size: src.Size, Bytes: int64(n)})
+			}}
+		}
+		return r
+	}
+}
+
+// progressReader calls fn with the number of bytes of every read
+type progressReader struct {
+	r  io.Reader
+	fn func(n int)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.fn(n)
+	}
+	return n, err
+}
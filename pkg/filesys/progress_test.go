@@ -0,0 +1,121 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// progressRecorder collects the events of a copy by type
+type progressRecorder struct {
+	mu     sync.Mutex
+	events map[ProgressType][]ProgressEvent
+	bytes  map[string]int64
+}
+
+func newProgressRecorder() *progressRecorder {
+	return &progressRecorder{events: map[ProgressType][]ProgressEvent{}, bytes: map[string]int64{}}
+}
+
+func (p *progressRecorder) record(e ProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events[e.Type] = append(p.events[e.Type], e)
+	p.bytes[e.Src.Path] += e.Bytes
+}
+
+func TestProgress(t *testing.T) {
+	ctx := context.Background()
+	src := NewMemFS(0)
+	_, err := src.MkDir(ctx, memURI("/src/sub"))
+	assert.NoError(t, err)
+	writeMemFile(t, src, "/src/a.txt", "aaaa")
+	writeMemFile(t, src, "/src/sub/b.txt", "bbbbbbbbbb")
+
+	// Between file systems, every file reports its bytes
+	p := newProgressRecorder()
+	dst := NewURI(LocalScheme, filepath.Join(t.TempDir(), "dst"))
+	assert.NoError(t, transfer(ctx, memURI("/src"), dst, true, src, NewLocalFS(), newCopyOptions([]CopyOption{WithProgress(p.record)})))
+	for _, typ := range []ProgressType{ProgressQueued, ProgressStart, ProgressDone} {
+		assert.Len(t, p.events[typ], 2, typ)
+	}
+	assert.Empty(t, p.events[ProgressFailed])
+	assert.Equal(t, int64(4), p.bytes["/src/a.txt"])
+	assert.Equal(t, int64(10), p.bytes["/src/sub/b.txt"])
+
+	// Through the journal and verified
+	p = newProgressRecorder()
+	j, err := CreateJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+	assert.NoError(t, err)
+	defer j.Close()
+	opts := newCopyOptions([]CopyOption{WithProgress(p.record), WithJournal(j), WithVerify(SHA256)})
+	assert.NoError(t, copyNode(ctx, mustGet(t, src, "/src/sub/b.txt"), dst.Join("b.txt"), src, NewLocalFS(), opts))
+	assert.Len(t, p.events[ProgressDone], 1)
+	assert.Equal(t, int64(10), p.bytes["/src/sub/b.txt"])
+
+	// A failed file
+	p = newProgressRecorder()
+	failures := &atomic.Int32{}
+	failures.Store(1)
+	flaky := flakyFS{MemFS: src, failures: failures, reads: &atomic.Int32{}}
+	err = copyNode(ctx, mustGet(t, src, "/src/sub/b.txt"), dst.Join("c.txt"), flaky, NewLocalFS(), newCopyOptions([]CopyOption{WithProgress(p.record)}))
+	assert.ErrorIs(t, err, errFlaky)
+	if assert.Len(t, p.events[ProgressFailed], 1) {
+		assert.True(t, errors.Is(p.events[ProgressFailed][0].Err, errFlaky))
+	}
+	assert.Equal(t, int64(5), p.bytes["/src/sub/b.txt"])
+}
+
+func TestProgressChan(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+	fs, err := client.FS(ctx, MemScheme)
+	assert.NoError(t, err)
+	writeMemFile(t, fs.(*MemFS), "/a.txt", "aaaa")
+
+	// A native copy reports the whole size of the file once copied
+	events := make(chan ProgressEvent, 10)
+	assert.NoError(t, client.Copy(ctx, memURI("/a.txt"), memURI("/b.txt"), false, WithProgressChan(events)))
+	close(events)
+	var types []ProgressType
+	for e := range events {
+		types = append(types, e.Type)
+		assert.Equal(t, memURI("/b.txt"), e.Dst)
+		assert.Equal(t, int64(4), e.Size)
+	}
+	assert.Equal(t, []ProgressType{ProgressQueued, ProgressStart, ProgressBytes, ProgressDone}, types)
+
+	// A native directory copy or move reports each of its files
+	_, err = fs.MkDir(ctx, memURI("/dir/sub"))
+	assert.NoError(t, err)
+	writeMemFile(t, fs.(*MemFS), "/dir/c.txt", "cc")
+	writeMemFile(t, fs.(*MemFS), "/dir/sub/d.txt", "ddd")
+	p := newProgressRecorder()
+	assert.NoError(t, client.Copy(ctx, memURI("/dir"), memURI("/copied"), true, WithProgress(p.record)))
+	assert.Len(t, p.events[ProgressQueued], 2)
+	assert.Len(t, p.events[ProgressDone], 2)
+	assert.Equal(t, int64(5), p.bytes["/dir/c.txt"]+p.bytes["/dir/sub/d.txt"])
+	p = newProgressRecorder()
+	assert.NoError(t, client.Move(ctx, memURI("/copied"), memURI("/moved"), true, WithProgress(p.record)))
+	assert.Len(t, p.events[ProgressDone], 2)
+	assert.Equal(t, memURI("/moved/sub/d.txt"), p.events[ProgressDone][1].Dst)
+	assert.Equal(t, int64(5), p.bytes["/copied/c.txt"]+p.bytes["/copied/sub/d.txt"])
+	for _, dir := range []string{"/dir", "/moved"} {
+		assert.NoError(t, client.Delete(ctx, memURI(dir), true))
+	}
+
+	// Sync queues the files it copies
+	p = newProgressRecorder()
+	dst := NewURI(LocalScheme, t.TempDir())
+	_, err = client.Sync(ctx, memURI("/"), dst, WithCopyOptions(WithProgress(p.record)))
+	assert.NoError(t, err)
+	assert.Len(t, p.events[ProgressQueued], 2)
+	assert.Len(t, p.events[ProgressDone], 2)
+	assert.Equal(t, int64(8), p.bytes["/a.txt"]+p.bytes["/b.txt"])
+}
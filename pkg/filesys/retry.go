@@ -0,0 +1,115 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+/*
+RetryPolicy retries the operations failing with a retryable error,
+waiting Backoff then twice longer after each attempt, up to MaxBackoff.
+
+The zero RetryPolicy makes a single attempt.
+*/
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts, values below 2 do not retry
+	Attempts int
+	// Backoff is the wait before the first retry
+	Backoff time.Duration
+	// MaxBackoff caps the wait between two attempts, 0 does not cap it
+	MaxBackoff time.Duration
+	// Jitter changes each wait randomly by up to this fraction of it, eg. 0.2 for ±20%
+	Jitter float64
+	// Retryable are the errors retried, checked with errors.Is, nil retries ErrTransient and ErrRateLimited
+	Retryable []error
+	// OnRetry is called before waiting for the next attempt, eg. to log the retries
+	OnRetry func(attempt int, err error, wait time.Duration)
+}
+
+// DefaultRetryPolicy is the retry policy of a Client created without WithRetryPolicy
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   3,
+	Backoff:    500 * time.Millisecond,
+	MaxBackoff: 30 * time.Second,
+	Jitter:     0.2,
+}
+
+// IsRetryable reports whether err is one of the errors p retries
+func (p RetryPolicy) IsRetryable(err error) bool {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = []error{ErrTransient, ErrRateLimited}
+	}
+	for _, target := range retryable {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Do calls fn until it succeeds, fails with an error that is not retryable
+or the attempts run out, and returns its last error.
+
+returns ctx.Err() if ctx is done while waiting for the next attempt
+*/
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	wait := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if err == nil || attempt >= p.Attempts || !p.IsRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+		delay := wait
+		if p.Jitter > 0 {
+			delay += time.Duration(float64(delay) * p.Jitter * (2*rand.Float64() - 1))
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		wait *= 2
+		if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+			wait = p.MaxBackoff
+		}
+	}
+}
+
+// permanentError stops the retries of Do, eg. for an error of a caller callback
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// WithRetryPolicy sets the retry policy of the operations of a Client and of its copies
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *Options) { o.Retry = p }
+}
+
+// WithRetry sets the retry policy of every copied file, instead of the one of the Client
+func WithRetry(p RetryPolicy) CopyOption {
+	return func(o *CopyOptions) { o.Retry = &p }
+}
+
+// retry returns the retry policy of the copied files, a single attempt if there is none
+func (o CopyOptions) retry() RetryPolicy {
+	if o.Retry == nil {
+		return RetryPolicy{}
+	}
+	return *o.Retry
+}
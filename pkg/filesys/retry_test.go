@@ -0,0 +1,218 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+	transient := &PathError{Op: "read", URI: memURI("/a"), Err: ErrTransient}
+	var waits []time.Duration
+	policy := RetryPolicy{Attempts: 4, Backoff: time.Millisecond, MaxBackoff: 3 * time.Millisecond,
+		OnRetry: func(attempt int, err error, wait time.Duration) {
+			assert.ErrorIs(t, err, ErrTransient)
+			waits = append(waits, wait)
+		}}
+
+	calls := 0
+	err := policy.Do(ctx, func() error {
+		calls++
+		return transient
+	})
+	assert.ErrorIs(t, err, ErrTransient)
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}, waits)
+
+	calls = 0
+	err = policy.Do(ctx, func() error {
+		calls++
+		if calls < 3 {
+			return transient
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// Not retryable, and a custom list of retryable errors
+	calls = 0
+	err = policy.Do(ctx, func() error {
+		calls++
+		return ErrNotFound
+	})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, calls)
+	policy.Retryable, policy.OnRetry = []error{ErrNotFound}, nil
+	calls = 0
+	_ = policy.Do(ctx, func() error {
+		calls++
+		return ErrNotFound
+	})
+	assert.Equal(t, 4, calls)
+
+	// The zero policy does not retry
+	calls = 0
+	_ = RetryPolicy{}.Do(ctx, func() error {
+		calls++
+		return transient
+	})
+	assert.Equal(t, 1, calls)
+
+	// Jitter stays within its fraction
+	policy = RetryPolicy{Attempts: 3, Backoff: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond, Jitter: 0.5,
+		OnRetry: func(attempt int, err error, wait time.Duration) {
+			assert.GreaterOrEqual(t, wait, 5*time.Millisecond)
+			assert.LessOrEqual(t, wait, 15*time.Millisecond)
+		}}
+	_ = policy.Do(ctx, func() error { return transient })
+
+	// A done context stops the wait
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	err = RetryPolicy{Attempts: 3, Backoff: time.Hour}.Do(ctx, func() error { return transient })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCopyRetry(t *testing.T) {
+	ctx := context.Background()
+	src := NewMemFS(0)
+	writeMemFile(t, src, "/a.txt", "0123456789")
+	failures := &atomic.Int32{}
+	failures.Store(2)
+	flaky := flakyFS{MemFS: src, failures: failures, reads: &atomic.Int32{}}
+	p := newProgressRecorder()
+	policy := RetryPolicy{Attempts: 3, Retryable: []error{errFlaky}}
+
+	// The copy starts over, the destination only has the last attempt
+	dst := NewURI(LocalScheme, filepath.Join(t.TempDir(), "a.txt"))
+	opts := newCopyOptions([]CopyOption{WithRetry(policy), WithProgress(p.record)})
+	assert.NoError(t, copyNode(ctx, mustGet(t, src, "/a.txt"), dst, flaky, NewLocalFS(), opts))
+	assert.Equal(t, int32(3), flaky.reads.Load())
+	assert.Len(t, p.events[ProgressRetry], 2)
+	assert.Len(t, p.events[ProgressDone], 1)
+	b, err := os.ReadFile(dst.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(b))
+
+	failures.Store(3)
+	err = copyNode(ctx, mustGet(t, src, "/a.txt"), memURI("/b.txt"), flaky, NewMemFS(0), opts)
+	assert.ErrorIs(t, err, errFlaky)
+}
+
+// walkFailFS fails a walk with a transient error after the first two nodes, once
+type walkFailFS struct {
+	*MemFS
+	failed *atomic.Bool
+}
+
+func (fs walkFailFS) Walk(ctx context.Context, root URI, opts WalkOptions, fn WalkFunc) error {
+	n := 0
+	return fs.MemFS.Walk(ctx, root, opts, func(node Node) error {
+		if n++; n > 2 && fs.failed.CompareAndSwap(false, true) {
+			return &PathError{Op: "walk", URI: node.URI, Err: ErrTransient}
+		}
+		return fn(node)
+	})
+}
+
+func TestClientRetry(t *testing.T) {
+	m := NewMemFS(0)
+	failed := &atomic.Bool{}
+	err := RegisterScheme("walkfail-test", func(Options) FS { return walkFailFS{MemFS: m, failed: failed} })
+	assert.NoError(t, err)
+	ctx := context.Background()
+	for _, name := range []string{"/a", "/b", "/c", "/d"} {
+		writeMemFile(t, m, name, name)
+	}
+	var retries int
+	client := NewClient(WithRetryPolicy(RetryPolicy{Attempts: 2, OnRetry: func(int, error, time.Duration) { retries++ }}))
+	defer client.Close()
+
+	// The walk goes on after the last node
+	var paths []string
+	err = client.Walk(ctx, NewURI("walkfail-test", "/"), WalkOptions{}, func(node Node) error {
+		paths = append(paths, node.URI.Path)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/a", "/b", "/c", "/d"}, paths)
+	assert.Equal(t, 1, retries)
+
+	// An error of fn is not retried
+	errStop := errors.New("stop")
+	err = client.Walk(ctx, NewURI("walkfail-test", "/"), WalkOptions{}, func(node Node) error {
+		return &PathError{Op: "stop", URI: node.URI, Err: errors.Join(errStop, ErrTransient)}
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, retries)
+
+	failed.Store(false)
+	nodes, err := client.List(ctx, NewURI("walkfail-test", "/"), false)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 4)
+}
+
+// lostReplyFS is a MemFS whose deletes and directory creations fail once done, like a lost reply
+type lostReplyFS struct {
+	*MemFS
+	lost *atomic.Bool
+}
+
+func (fs lostReplyFS) Delete(ctx context.Context, uri URI, recursive bool) error {
+	if err := fs.MemFS.Delete(ctx, uri, recursive); err != nil || fs.lost.Swap(true) {
+		return err
+	}
+	return &PathError{Op: "delete", URI: uri, Err: ErrTransient}
+}
+
+func (fs lostReplyFS) MkDir(ctx context.Context, uri URI) (Node, error) {
+	node, err := fs.MemFS.MkDir(ctx, uri)
+	if err != nil || fs.lost.Swap(true) {
+		return node, err
+	}
+	return Node{}, &PathError{Op: "mkdir", URI: uri, Err: ErrTransient}
+}
+
+func TestClientRetryDone(t *testing.T) {
+	m := NewMemFS(0)
+	lost := &atomic.Bool{}
+	err := RegisterScheme("lostreply-test", func(Options) FS { return lostReplyFS{MemFS: m, lost: lost} })
+	assert.NoError(t, err)
+	ctx := context.Background()
+	client := NewClient(WithRetryPolicy(RetryPolicy{Attempts: 2}))
+	defer client.Close()
+	uri := func(p string) URI { return NewURI("lostreply-test", p) }
+
+	node, err := client.MkDir(ctx, uri("/dir"))
+	assert.NoError(t, err)
+	assert.True(t, node.IsDir)
+	assert.Equal(t, "/dir", node.URI.Path)
+
+	writeMemFile(t, m, "/a", "a")
+	lost.Store(false)
+	assert.NoError(t, client.Delete(ctx, uri("/a"), false))
+	_, err = m.Get(ctx, memURI("/a"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// A first attempt still fails as before
+	assert.ErrorIs(t, client.Delete(ctx, uri("/a"), false), ErrNotFound)
+	_, err = client.MkDir(ctx, uri("/dir"))
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	// The source of a move is deleted with the policy of the call
+	client = NewClient(WithRetryPolicy(RetryPolicy{}))
+	defer client.Close()
+	writeMemFile(t, m, "/b", "b")
+	lost.Store(false)
+	assert.NoError(t, client.Move(ctx, uri("/b"), memURI("/moved"), false, WithRetry(RetryPolicy{Attempts: 2})))
+	_, err = m.Get(ctx, memURI("/b"))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
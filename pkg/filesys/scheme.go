@@ -0,0 +1,133 @@
+package filesys
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+const (
+	LocalScheme     string = ""
+	GCPBucketScheme string = "gs"
+	MemScheme       string = "mem"
+)
+
+var (
+	ErrUnknownScheme    = errors.New("unknown scheme")
+	ErrSchemeRegistered = errors.New("scheme already registered")
+	ErrInvalidScheme    = errors.New("invalid scheme name")
+)
+
+// SchemeError reports a failed scheme registration or lookup
+type SchemeError struct {
+	Scheme string
+	Err    error
+}
+
+func (e *SchemeError) Error() string { return e.Err.Error() + " : " + e.Scheme }
+func (e *SchemeError) Unwrap() error { return e.Err }
+
+// SchemeFactory creates a new, not yet connected, FS for a scheme configured with the client options
+type SchemeFactory func(opts Options) FS
+
+// schemeEntry is a registered scheme
+type schemeEntry struct {
+	factory   SchemeFactory
+	authority bool
+}
+
+// SchemeOption sets an optional parameter of a registered scheme
+type SchemeOption func(*schemeEntry)
+
+/*
+WithHostAuthority makes ParseURI read the user, host and port of the scheme URIs,
+as in sftp://user@host:22/path, instead of keeping them in the path.
+*/
+func WithHostAuthority() SchemeOption {
+	return func(e *schemeEntry) { e.authority = true }
+}
+
+var (
+	schemesMu sync.RWMutex
+	schemes   = map[string]schemeEntry{
+		GCPBucketScheme: {factory: func(opts Options) FS { return NewGCPBucketFS(gcpClientOptions(opts)...) }},
+		LocalScheme:     {factory: func(opts Options) FS { return &LocalFS{InPlace: opts.InPlace, Symlinks: opts.Symlinks} }},
+		MemScheme:       {factory: func(opts Options) FS { return NewMemFS(opts.MaxMemorySize) }},
+	}
+)
+
+var schemeRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*$`)
+
+/*
+RegisterScheme makes a file system available under a URI scheme,
+for ParseURI, SchemeFS and the manager functions.
+
+It is safe for concurrent use, it is usually called from an init function.
+
+returns a *SchemeError wrapping:
+  - ErrInvalidScheme if name is not a valid URI scheme, is the reserved file scheme or factory is nil
+  - ErrSchemeRegistered if name is already registered
+*/
+func RegisterScheme(name string, factory SchemeFactory, opts ...SchemeOption) error {
+	if !schemeRe.MatchString(name) || name == FileScheme || factory == nil {
+		return &SchemeError{Scheme: name, Err: ErrInvalidScheme}
+	}
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	if _, ok := schemes[name]; ok {
+		return &SchemeError{Scheme: name, Err: ErrSchemeRegistered}
+	}
+	entry := schemeEntry{factory: factory}
+	for _, option := range opts {
+		option(&entry)
+	}
+	schemes[name] = entry
+	return nil
+}
+
+// ValidScheme reports whether a scheme is registered
+func ValidScheme(scheme string) bool {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+	_, ok := schemes[scheme]
+	return ok
+}
+
+// schemeHasAuthority reports whether a scheme was registered with WithHostAuthority
+func schemeHasAuthority(scheme string) bool {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+	return schemes[scheme].authority
+}
+
+// Schemes returns the sorted names of the registered schemes
+func Schemes() []string {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+SchemeFS creates a new FS for a registered scheme, with the default options.
+
+returns a *SchemeError wrapping ErrUnknownScheme if the scheme is not registered
+*/
+func SchemeFS(scheme string) (FS, error) {
+	return newSchemeFS(scheme, Options{})
+}
+
+func newSchemeFS(scheme string, opts Options) (FS, error) {
+	schemesMu.RLock()
+	entry, ok := schemes[scheme]
+	schemesMu.RUnlock()
+	if !ok {
+		return nil, &SchemeError{Scheme: scheme, Err: ErrUnknownScheme}
+	}
+	return entry.factory(opts), nil
+}
@@ -0,0 +1,352 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"time"
+)
+
+// SyncOp is what Sync does to a destination file
+type SyncOp string
+
+const (
+	SyncCreate SyncOp = "create"
+	SyncUpdate SyncOp = "update"
+	SyncDelete SyncOp = "delete"
+)
+
+// SyncAction is a file Sync creates, updates or deletes
+type SyncAction struct {
+	Op SyncOp
+	// Src is empty for SyncDelete
+	Src  URI
+	Dst  URI
+	Size int64
+}
+
+// SyncSummary counts what Sync did, or would do in dry run mode
+type SyncSummary struct {
+	Actions   []SyncAction
+	Created   int
+	Updated   int
+	Deleted   int
+	Unchanged int
+	// Bytes is the size of the created and updated files
+	Bytes int64
+}
+
+func (s *SyncSummary) add(action SyncAction) {
+	s.Actions = append(s.Actions, action)
+	switch action.Op {
+	case SyncCreate:
+		s.Created++
+		s.Bytes += action.Size
+	case SyncUpdate:
+		s.Updated++
+		s.Bytes += action.Size
+	case SyncDelete:
+		s.Deleted++
+	}
+}
+
+// SyncOptions configures Client.Sync
+type SyncOptions struct {
+	CopyOptions
+	// Checksum compares files by checksum instead of size and modification time
+	Checksum bool
+	// Delete removes the destination files missing from the source
+	Delete bool
+	// DryRun computes the actions without running them
+	DryRun bool
+}
+
+// SyncOption sets a SyncOptions field
+type SyncOption func(*SyncOptions)
+
+// WithChecksum compares files by checksum instead of size and modification time
+func WithChecksum() SyncOption {
+	return func(o *SyncOptions) { o.Checksum = true }
+}
+
+// WithDelete removes the destination files missing from the source
+func WithDelete() SyncOption {
+	return func(o *SyncOptions) { o.Delete = true }
+}
+
+// WithSyncDryRun only computes the actions, the returned summary tells what Sync would do
+func WithSyncDryRun() SyncOption {
+	return func(o *SyncOptions) { o.DryRun = true }
+}
+
+// WithCopyOptions sets the options of the copies done by Sync
+func WithCopyOptions(opts ...CopyOption) SyncOption {
+	return func(o *SyncOptions) {
+		for _, opt := range opts {
+			opt(&o.CopyOptions)
+		}
+	}
+}
+
+/*
+Sync makes dst a mirror of src, copying only the new and changed files.
+
+Files are compared by size and modification time, a source file newer than
+its copy is changed, or by checksum WithChecksum. src and dst can be on
+any file systems. The content of a src directory is synced into dst, a
+file src is synced into an existing directory dst like Copy does.
+WithCopyOptions(WithFilter(f)) ignores the files f excludes on both sides,
+an excluded destination file is never deleted, nor the directories holding it.
+
+returns the summary of the actions and:
+  - ErrNotFound if src does not exist
+  - a *TransferError with the files that failed
+*/
+func (c *Client) Sync(ctx context.Context, src, dst URI, opts ...SyncOption) (SyncSummary, error) {
+	o := SyncOptions{CopyOptions: newCopyOptions(nil)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Retry == nil {
+		policy := c.opts.Retry
+		o.Retry = &policy
+	}
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return SyncSummary{}, err
+	}
+	defer release()
+	srcFS, err := c.FS(ctx, src.Scheme)
+	if err != nil {
+		return SyncSummary{}, err
+	}
+	dstFS, err := c.FS(ctx, dst.Scheme)
+	if err != nil {
+		return SyncSummary{}, err
+	}
+	if o.Verify && o.Hash == "" {
+		o.Hash = verifyHash(dstFS)
+	}
+	s := syncer{srcFS: srcFS, dstFS: dstFS, src: src, dst: dst, opts: o}
+	return s.sync(ctx)
+}
+
+// Sync mirrors src to dst with the DefaultClient, see Client.Sync
+func Sync(ctx context.Context, src, dst URI, opts ...SyncOption) (SyncSummary, error) {
+	return DefaultClient.Sync(ctx, src, dst, opts...)
+}
+
+type syncer struct {
+	srcFS, dstFS FS
+	src, dst     URI
+	opts         SyncOptions
+}
+
+func (s *syncer) sync(ctx context.Context) (SyncSummary, error) {
+	var summary SyncSummary
+	srcNodes, err := syncTree(ctx, s.srcFS, s.src, s.opts.Filter)
+	if err != nil {
+		return summary, err
+	}
+	if node, isFile := srcNodes["."]; isFile {
+		if s.dst, err = copyTarget(ctx, s.dstFS, node, s.dst); err != nil {
+			return summary, err
+		}
+	}
+	// Excluded destination files are not deleted either
+	dstNodes, err := syncTree(ctx, s.dstFS, s.dst, s.opts.Filter)
+	var dirs []string
+	if errors.Is(err, ErrNotFound) {
+		dstNodes = map[string]Node{}
+		// A missing dst is created for the content of a src directory
+		if _, isFile := srcNodes["."]; !isFile {
+			dirs = append(dirs, ".")
+		}
+	} else if err != nil {
+		return summary, err
+	}
+
+	var errs []error
+	for _, rel := range sortedKeys(srcNodes) {
+		node := srcNodes[rel]
+		existing, exists := dstNodes[rel]
+		switch {
+		case exists && existing.IsDir != node.IsDir:
+			kind := ErrNotDir
+			if existing.IsDir {
+				kind = ErrIsDir
+			}
+			errs = append(errs, &PathError{Op: "sync", URI: existing.URI, Err: kind})
+		case node.IsDir:
+			if !exists {
+				dirs = append(dirs, rel)
+			}
+		case !exists:
+			summary.add(SyncAction{Op: SyncCreate, Src: node.URI, Dst: s.target(rel), Size: node.Size})
+		default:
+			changed, err := s.changed(ctx, node, existing)
+			if err != nil {
+				errs = append(errs, err)
+			} else if changed {
+				summary.add(SyncAction{Op: SyncUpdate, Src: node.URI, Dst: existing.URI, Size: node.Size})
+			} else {
+				summary.Unchanged++
+			}
+		}
+	}
+	if s.opts.Delete {
+		excluded, err := s.excludedDirs(ctx, dstNodes)
+		if err != nil {
+			return summary, errors.Join(transferError(errs), err)
+		}
+		// A deleted directory is deleted with its content, one holding excluded files is kept
+		deleted := map[string]bool{}
+		for _, rel := range sortedKeys(dstNodes) {
+			if _, ok := srcNodes[rel]; ok || hasDeletedParent(deleted, rel) || excluded[rel] {
+				continue
+			}
+			node := dstNodes[rel]
+			deleted[rel] = node.IsDir
+			summary.add(SyncAction{Op: SyncDelete, Dst: node.URI, Size: node.Size})
+		}
+	}
+	if s.opts.DryRun || ctx.Err() != nil {
+		return summary, errors.Join(ctx.Err(), transferError(errs))
+	}
+
+	for _, rel := range dirs {
+		if _, err := s.dstFS.MkDir(ctx, s.target(rel)); err != nil && !errors.Is(err, ErrAlreadyExists) {
+			errs = append(errs, err)
+		}
+	}
+	for _, action := range summary.Actions {
+		if action.Op != SyncDelete {
+			s.opts.progress(ProgressEvent{Type: ProgressQueued, Src: action.Src, Dst: action.Dst, Size: action.Size})
+		}
+	}
+	err = runPool(ctx, s.opts.Parallel, func(send func(SyncAction) error) error {
+		for _, action := range summary.Actions {
+			if err := send(action); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(action SyncAction) error {
+		if action.Op == SyncDelete {
+			return retryDelete(ctx, s.opts.retry(), s.dstFS, action.Dst, true, nil)
+		}
+		srcNode, err := s.srcFS.Get(ctx, action.Src)
+		if err != nil {
+			return err
+		}
+		return copyNode(ctx, srcNode, action.Dst, s.srcFS, s.dstFS, s.opts.CopyOptions)
+	})
+	return summary, errors.Join(transferError(errs), err)
+}
+
+/*
+excludedDirs returns the destination directories holding a file the filter
+excludes, the paths of dstNodes are the files it keeps.
+*/
+func (s *syncer) excludedDirs(ctx context.Context, dstNodes map[string]Node) (map[string]bool, error) {
+	if s.opts.Filter == nil || len(dstNodes) == 0 {
+		return nil, nil
+	}
+	all, err := syncTree(ctx, s.dstFS, s.dst, nil)
+	if err != nil {
+		return nil, err
+	}
+	dirs := map[string]bool{}
+	for rel := range all {
+		if _, ok := dstNodes[rel]; ok {
+			continue
+		}
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	return dirs, nil
+}
+
+// target returns the destination of the source path rel
+func (s *syncer) target(rel string) URI {
+	if rel == "." {
+		return s.dst
+	}
+	return s.dst.Join(rel)
+}
+
+/*
+changed reports whether the file src differs from its copy dst.
+
+By checksum, the sizes are compared first then a checksum both nodes store,
+or SHA256 of their content. Otherwise a different size or a source
+modified after its copy, to the second, is a change.
+*/
+func (s *syncer) changed(ctx context.Context, src, dst Node) (bool, error) {
+	if src.Size != dst.Size {
+		return true, nil
+	}
+	if !s.opts.Checksum {
+		return src.ModTime.Truncate(time.Second).After(dst.ModTime.Truncate(time.Second)), nil
+	}
+	algo := SHA256
+	for _, a := range []HashAlgorithm{CRC32C, MD5} {
+		if src.Checksums[a] != nil && dst.Checksums[a] != nil {
+			algo = a
+			break
+		}
+	}
+	srcSum, err := checksumFS(ctx, s.srcFS, src.URI, algo)
+	if err != nil {
+		return false, err
+	}
+	dstSum, err := checksumFS(ctx, s.dstFS, dst.URI, algo)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(srcSum, dstSum), nil
+}
+
+// syncTree returns the nodes under root f keeps by path relative to it, a file root is "."
+func syncTree(ctx context.Context, fs FS, root URI, f *Filter) (map[string]Node, error) {
+	nodes := map[string]Node{}
+	err := f.Walk(ctx, fs, root, WalkOptions{Recursive: true}, func(node Node) error {
+		rel, err := root.Rel(node.URI)
+		if err != nil {
+			return &PathError{Op: "sync", URI: node.URI, Err: err}
+		}
+		nodes[rel] = node
+		return nil
+	})
+	return nodes, err
+}
+
+// hasDeletedParent reports whether a directory containing rel is deleted
+func hasDeletedParent(deleted map[string]bool, rel string) bool {
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if deleted[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]Node) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// transferError returns errs as a *TransferError, nil if there is none
+func transferError(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &TransferError{Errs: errs}
+}
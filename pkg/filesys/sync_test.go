@@ -0,0 +1,101 @@
+package filesys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+	fs, err := client.FS(ctx, MemScheme)
+	assert.NoError(t, err)
+	m := fs.(*MemFS)
+	_, err = m.MkDir(ctx, memURI("/src/sub"))
+	assert.NoError(t, err)
+	writeMemFile(t, m, "/src/a.txt", "a")
+	writeMemFile(t, m, "/src/sub/b.txt", "b")
+	src := memURI("/src")
+	dst := NewURI(LocalScheme, filepath.Join(t.TempDir(), "mirror"))
+	read := func(p string) string {
+		b, err := os.ReadFile(filepath.Join(dst.Path, p))
+		assert.NoError(t, err)
+		return string(b)
+	}
+
+	summary, err := client.Sync(ctx, src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Created)
+	assert.Equal(t, int64(2), summary.Bytes)
+	assert.Equal(t, "b", read("sub/b.txt"))
+
+	summary, err = client.Sync(ctx, src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Unchanged)
+	assert.Empty(t, summary.Actions)
+
+	// Changed, new and extraneous files
+	writeMemFile(t, m, "/src/a.txt", "changed")
+	writeMemFile(t, m, "/src/sub/c.txt", "c")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dst.Path, "old", "dir"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dst.Path, "old", "dir", "x.txt"), []byte("x"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dst.Path, "sub", "y.txt"), []byte("y"), 0644))
+
+	summary, err = client.Sync(ctx, src, dst, WithDelete(), WithSyncDryRun())
+	assert.NoError(t, err)
+	assert.Equal(t, []SyncAction{
+		{Op: SyncUpdate, Src: memURI("/src/a.txt"), Dst: dst.Join("a.txt"), Size: 7},
+		{Op: SyncCreate, Src: memURI("/src/sub/c.txt"), Dst: dst.Join("sub", "c.txt"), Size: 1},
+		{Op: SyncDelete, Dst: dst.Join("old"), Size: 0},
+		{Op: SyncDelete, Dst: dst.Join("sub", "y.txt"), Size: 1},
+	}, summary.Actions)
+	assert.Equal(t, "a", read("a.txt"), "dry run changes nothing")
+
+	summary, err = client.Sync(ctx, src, dst, WithDelete(), WithCopyOptions(WithParallel(2), WithVerify("")))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Created)
+	assert.Equal(t, 1, summary.Updated)
+	assert.Equal(t, 2, summary.Deleted)
+	assert.Equal(t, 1, summary.Unchanged)
+	assert.Equal(t, "changed", read("a.txt"))
+	assert.Equal(t, "c", read("sub/c.txt"))
+	_, err = os.Stat(filepath.Join(dst.Path, "old"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(filepath.Join(dst.Path, "sub", "y.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Same size and an older source, only the checksum sees the change
+	writeMemFile(t, m, "/src/sub/c.txt", "C")
+	old := time.Now().Add(-time.Hour)
+	m.lookup(memComponents(memURI("/src/sub/c.txt"))).modTime = old
+	summary, err = client.Sync(ctx, src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, summary.Updated)
+	summary, err = client.Sync(ctx, src, dst, WithChecksum())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Updated)
+	assert.Equal(t, "C", read("sub/c.txt"))
+
+	_, err = client.Sync(ctx, memURI("/missing"), dst)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// A missing destination is created for a source without sub directories
+	flat := NewURI(LocalScheme, filepath.Join(t.TempDir(), "flat"))
+	summary, err = client.Sync(ctx, memURI("/src/sub"), flat)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Created)
+
+	// A file is synced into an existing directory
+	summary, err = client.Sync(ctx, memURI("/src/a.txt"), flat)
+	assert.NoError(t, err)
+	assert.Equal(t, []SyncAction{{Op: SyncCreate, Src: memURI("/src/a.txt"), Dst: flat.Join("a.txt"), Size: 7}}, summary.Actions)
+	summary, err = client.Sync(ctx, memURI("/src/a.txt"), flat)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Unchanged)
+}
@@ -0,0 +1,284 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// DefaultParallel is the number of files transferred at once when no WithParallel option is given
+const DefaultParallel = 8
+
+// CopyOptions configures Client.Copy and Client.Move
+type CopyOptions struct {
+	// Parallel is the number of files transferred at once between different file systems
+	Parallel int
+	// Journal records the transfer progress between different file systems, to resume it
+	Journal *Journal
+	// Verify checks the checksum of every copied file with the Hash algorithm
+	Verify bool
+	// Hash is the verification algorithm, empty picks one the destination stores
+	Hash HashAlgorithm
+	// Filter selects the files copied from a source directory
+	Filter *Filter
+	// Progress receives the progress events of the copied files
+	Progress ProgressFunc
+	// Bandwidth limits the throughput of the copied files
+	Bandwidth *BandwidthLimiter
+	// Retry is the retry policy of each copied file, nil uses the one of the Client
+	Retry *RetryPolicy
+	// Preserve keeps the modification time, permissions and owner of the copied files
+	Preserve bool
+	// Writer are the options of the writers of the copied files, eg. their metadata
+	Writer []WriterOption
+}
+
+// CopyOption sets a CopyOptions field
+type CopyOption func(*CopyOptions)
+
+// WithParallel sets the number of files transferred at once, values below 1 use 1
+func WithParallel(n int) CopyOption {
+	return func(o *CopyOptions) { o.Parallel = n }
+}
+
+// WithJournal records the progress in j, files it marks as done are skipped
+func WithJournal(j *Journal) CopyOption {
+	return func(o *CopyOptions) { o.Journal = j }
+}
+
+/*
+WithVerify checks every copied file against the checksum of its source stream,
+with algo or, if empty, CRC32C for GCS and SHA256 for local files.
+*/
+func WithVerify(algo HashAlgorithm) CopyOption {
+	return func(o *CopyOptions) { o.Verify, o.Hash = true, algo }
+}
+
+/*
+WithFilter only copies the files of a source directory that f keeps,
+Move then only deletes these files from the source.
+*/
+func WithFilter(f *Filter) CopyOption {
+	return func(o *CopyOptions) { o.Filter = f }
+}
+
+func newCopyOptions(opts []CopyOption) CopyOptions {
+	o := CopyOptions{Parallel: DefaultParallel}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Parallel < 1 {
+		o.Parallel = 1
+	}
+	return o
+}
+
+/*
+TransferError collects the errors of the files that failed in a recursive transfer.
+
+The transfer goes on after a file fails, Errs holds one error per failed file.
+*/
+type TransferError struct {
+	Errs []error
+}
+
+func (e *TransferError) Error() string {
+	if len(e.Errs) == 1 {
+		return e.Errs[0].Error()
+	}
+	return fmt.Sprintf("%d files failed, first: %v", len(e.Errs), e.Errs[0])
+}
+
+func (e *TransferError) Unwrap() []error { return e.Errs }
+
+/*
+copyTarget returns the URI src is copied to on fs, following the rules of POSIX cp -r:
+
+  - if dst is an existing directory, src is copied under it with its own name
+  - if dst does not exist, src is copied to dst, a file src copied to a
+    dst ending with "/" goes under it with its own name
+  - a file copied onto an existing file overwrites it
+  - a directory copied onto an existing directory merges into it
+
+A trailing slash on src does not change the result.
+
+returns:
+  - ErrIsDir if src is a file and the target is an existing directory
+  - ErrNotDir if src is a directory and the target is an existing file
+  - ErrInvalidURI if the target is src itself, or inside the directory src
+*/
+func copyTarget(ctx context.Context, fs FS, src Node, dst URI) (URI, error) {
+	target := dst.Join()
+	dstNode, err := fs.Get(ctx, dst)
+	switch {
+	case err == nil && dstNode.IsDir:
+		target = dst.Join(src.URI.Name)
+		dstNode, err = fs.Get(ctx, target)
+		if errors.Is(err, ErrNotFound) {
+			return target, checkCopyInside(src, target)
+		} else if err != nil {
+			return target, err
+		}
+	case errors.Is(err, ErrNotFound):
+		if !src.IsDir && dst.IsDirLike() {
+			target = dst.Join(src.URI.Name)
+		}
+		return target, checkCopyInside(src, target)
+	case err != nil:
+		return target, err
+	}
+	switch {
+	case !src.IsDir && dstNode.IsDir:
+		return target, &PathError{Op: "copy", URI: target, Err: ErrIsDir}
+	case src.IsDir && !dstNode.IsDir:
+		return target, &PathError{Op: "copy", URI: target, Err: ErrNotDir}
+	}
+	return target, checkCopyInside(src, target)
+}
+
+// checkCopyInside fails with ErrInvalidURI when src would be copied onto or inside itself
+func checkCopyInside(src Node, target URI) error {
+	rel, err := src.URI.Rel(target)
+	if err == nil && (src.IsDir || rel == ".") {
+		return &PathError{Op: "copy", URI: target, Err: ErrInvalidURI}
+	}
+	return nil
+}
+
+/*
+copyNode copies the file src to dst, through the journal if there is one,
+reporting its start and its end to opts.Progress. WithPreserve the
+attributes of src are then set on dst.
+
+A failed attempt is retried following opts.Retry: the copy starts over,
+or goes on from the journal offset.
+*/
+func copyNode(ctx context.Context, src Node, dst URI, srcFS, dstFS FS, opts CopyOptions) error {
+	opts.progress(ProgressEvent{Type: ProgressStart, Src: src.URI, Dst: dst, Size: src.Size})
+	stream := opts.stream(src, dst)
+	policy := opts.retry()
+	onRetry := policy.OnRetry
+	policy.OnRetry = func(attempt int, err error, wait time.Duration) {
+		opts.progress(ProgressEvent{Type: ProgressRetry, Src: src.URI, Dst: dst, Size: src.Size, Err: err})
+		if onRetry != nil {
+			onRetry(attempt, err, wait)
+		}
+	}
+	err := policy.Do(ctx, func() (err error) {
+		link := src.Mode&fs.ModeSymlink != 0
+		switch {
+		case link:
+			err = copyLink(ctx, src, dst, dstFS, opts.Writer...)
+		case opts.Journal != nil:
+			err = copyJournaled(ctx, src, dst, srcFS, dstFS, opts.Journal, opts.Hash, stream, opts.Writer...)
+		case opts.Verify:
+			err = copyFileVerified(ctx, src.URI, dst, srcFS, dstFS, opts.Hash, stream, opts.Writer...)
+		default:
+			err = copyFile(ctx, src.URI, dst, srcFS, dstFS, stream, opts.Writer...)
+		}
+		if err == nil && opts.Preserve && !link {
+			err = preserveAttrs(ctx, src, dst, dstFS)
+		}
+		return err
+	})
+	if err != nil {
+		opts.progress(ProgressEvent{Type: ProgressFailed, Src: src.URI, Dst: dst, Size: src.Size, Err: err})
+	} else {
+		opts.progress(ProgressEvent{Type: ProgressDone, Src: src.URI, Dst: dst, Size: src.Size})
+	}
+	return err
+}
+
+// relNode is a node with its path relative to the transfer source
+type relNode struct {
+	Node
+	rel string
+}
+
+/*
+transfer copies the files under src to dst with a pool of opts.Parallel workers.
+
+Each file keeps its path relative to src, directories are created on dst
+as they are walked. Files are handed to the workers while src is walked,
+so the transfer starts before the whole tree is listed.
+
+returns:
+  - the Walk error if listing src fails
+  - a *TransferError if some files failed
+  - ctx.Err() if ctx is done before the transfer ends
+*/
+func transfer(ctx context.Context, src, dst URI, recursive bool, srcFS, dstFS FS, opts CopyOptions) error {
+	return runPool(ctx, opts.Parallel, func(send func(relNode) error) error {
+		return opts.Filter.Walk(ctx, srcFS, src, WalkOptions{Recursive: recursive}, func(node Node) error {
+			rel, err := src.Rel(node.URI)
+			if err != nil {
+				return &PathError{Op: "copy", URI: node.URI, Err: err}
+			}
+			if node.IsDir {
+				_, err := dstFS.MkDir(ctx, dst.Join(rel))
+				if err != nil && !errors.Is(err, ErrAlreadyExists) {
+					return err
+				}
+				return nil
+			}
+			opts.progress(ProgressEvent{Type: ProgressQueued, Src: node.URI, Dst: dst.Join(rel), Size: node.Size})
+			return send(relNode{Node: node, rel: rel})
+		})
+	}, func(node relNode) error {
+		return copyNode(ctx, node.Node, dst.Join(node.rel), srcFS, dstFS, opts)
+	})
+}
+
+/*
+runPool calls work on parallel workers for every job that produce sends.
+
+A failed job does not stop the others, the error of produce is returned
+joined with a *TransferError holding the failed jobs ones. Once ctx is
+done the remaining jobs are dropped and ctx.Err() is returned.
+*/
+func runPool[T any](ctx context.Context, parallel int, produce func(send func(T) error) error, work func(T) error) error {
+	jobs := make(chan T, parallel)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := work(job); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	produceErr := produce(func(job T) error {
+		select {
+		case jobs <- job:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errors.Join(produceErr, &TransferError{Errs: errs})
+	}
+	return produceErr
+}
@@ -0,0 +1,277 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowFS is a MemFS whose writers fail for names containing "bad" and count the writers open at once
+type slowFS struct {
+	*MemFS
+	open, maxOpen *atomic.Int32
+}
+
+type slowWriter struct {
+	io.WriteCloser
+	fs slowFS
+}
+
+func (fs slowFS) Writer(ctx context.Context, uri URI, opts ...WriterOption) (io.WriteCloser, error) {
+	if strings.Contains(uri.Name, "bad") {
+		return nil, &PathError{Op: "create", URI: uri, Err: ErrPermission}
+	}
+	w, err := fs.MemFS.Writer(ctx, uri, opts...)
+	if err != nil {
+		return nil, err
+	}
+	n := fs.open.Add(1)
+	for {
+		max := fs.maxOpen.Load()
+		if n <= max || fs.maxOpen.CompareAndSwap(max, n) {
+			break
+		}
+	}
+	return slowWriter{WriteCloser: w, fs: fs}, nil
+}
+
+func (w slowWriter) Close() error {
+	time.Sleep(5 * time.Millisecond)
+	w.fs.open.Add(-1)
+	return w.WriteCloser.Close()
+}
+
+func newTransferTree(t *testing.T, files ...string) (*MemFS, URI) {
+	src := NewMemFS(0)
+	root := memURI("/src")
+	_, err := src.MkDir(context.Background(), root)
+	assert.NoError(t, err)
+	for _, name := range files {
+		writeMemFile(t, src, "/src/"+name, name)
+	}
+	return src, root
+}
+
+func TestTransferParallel(t *testing.T) {
+	var files []string
+	for i := 0; i < 20; i++ {
+		files = append(files, fmt.Sprintf("file-%02d.txt", i))
+	}
+	src, root := newTransferTree(t, files...)
+	var open, maxOpen atomic.Int32
+	dst := slowFS{MemFS: NewMemFS(0), open: &open, maxOpen: &maxOpen}
+
+	err := transfer(context.Background(), root, memURI("/"), true, src, dst, newCopyOptions([]CopyOption{WithParallel(4)}))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), maxOpen.Load())
+	for _, name := range files {
+		assert.Equal(t, name, readMemFile(t, dst.MemFS, "/"+name))
+	}
+}
+
+func TestTransferErrors(t *testing.T) {
+	src, root := newTransferTree(t, "a.txt", "bad-1.txt", "b.txt", "bad-2.txt")
+	var open, maxOpen atomic.Int32
+	dst := slowFS{MemFS: NewMemFS(0), open: &open, maxOpen: &maxOpen}
+
+	err := transfer(context.Background(), root, memURI("/"), true, src, dst, newCopyOptions(nil))
+	var transferErr *TransferError
+	assert.ErrorAs(t, err, &transferErr)
+	assert.Len(t, transferErr.Errs, 2)
+	assert.ErrorIs(t, err, ErrPermission)
+	// The other files are copied anyway
+	assert.Equal(t, "a.txt", readMemFile(t, dst.MemFS, "/a.txt"))
+	assert.Equal(t, "b.txt", readMemFile(t, dst.MemFS, "/b.txt"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = transfer(ctx, root, memURI("/"), true, src, dst, newCopyOptions(nil))
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestCopyOptions(t *testing.T) {
+	assert.Equal(t, DefaultParallel, newCopyOptions(nil).Parallel)
+	assert.Equal(t, 3, newCopyOptions([]CopyOption{WithParallel(3)}).Parallel)
+	assert.Equal(t, 1, newCopyOptions([]CopyOption{WithParallel(0)}).Parallel)
+}
+
+func TestCopyTarget(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemFS(0)
+	_, err := m.MkDir(ctx, memURI("/src/sub"))
+	assert.NoError(t, err)
+	_, err = m.MkDir(ctx, memURI("/dst/src"))
+	assert.NoError(t, err)
+	_, err = m.MkDir(ctx, memURI("/dst/sub/a.txt"))
+	assert.NoError(t, err)
+	writeMemFile(t, m, "/src/a.txt", "a")
+	writeMemFile(t, m, "/dst/a.txt", "a")
+	writeMemFile(t, m, "/dst/file", "f")
+	file, err := m.Get(ctx, memURI("/src/a.txt"))
+	assert.NoError(t, err)
+	dir, err := m.Get(ctx, memURI("/src/"))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		src    Node
+		dst    string
+		target string
+		err    error
+	}{
+		{name: "file to new file", src: file, dst: "/new.txt", target: "/new.txt"},
+		{name: "file to new dir", src: file, dst: "/new/", target: "/new/a.txt"},
+		{name: "file to dir", src: file, dst: "/", target: "/a.txt"},
+		{name: "file to existing file", src: file, dst: "/dst/a.txt", target: "/dst/a.txt"},
+		{name: "file to dir with file", src: file, dst: "/dst", target: "/dst/a.txt"},
+		{name: "file onto itself", src: file, dst: "/src/a.txt", err: ErrInvalidURI},
+		{name: "file to its dir", src: file, dst: "/src", err: ErrInvalidURI},
+		{name: "file to dir with dir", src: file, dst: "/dst/sub", err: ErrIsDir},
+		{name: "dir to new dir", src: dir, dst: "/copy", target: "/copy"},
+		{name: "dir to new dir with slash", src: dir, dst: "/copy/", target: "/copy"},
+		{name: "dir to dir", src: dir, dst: "/dst/src", target: "/dst/src/src"},
+		{name: "dir to its parent", src: dir, dst: "/", err: ErrInvalidURI},
+		{name: "dir merged to dir", src: dir, dst: "/dst", target: "/dst/src"},
+		{name: "dir to file", src: dir, dst: "/dst/file", err: ErrNotDir},
+		{name: "dir into itself", src: dir, dst: "/src/sub", err: ErrInvalidURI},
+		{name: "dir to new dir into itself", src: dir, dst: "/src/new", err: ErrInvalidURI},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			target, err := copyTarget(ctx, m, tc.src, memURI(tc.dst))
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.target, target.Path)
+		})
+	}
+}
+
+func TestClientCopyKeepsStructure(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+	fs, err := client.FS(ctx, MemScheme)
+	assert.NoError(t, err)
+	m := fs.(*MemFS)
+	_, err = m.MkDir(ctx, memURI("/photos/2023/summer"))
+	assert.NoError(t, err)
+	_, err = m.MkDir(ctx, memURI("/photos/2024"))
+	assert.NoError(t, err)
+	writeMemFile(t, m, "/photos/a.jpg", "a")
+	writeMemFile(t, m, "/photos/2023/a.jpg", "2023")
+	writeMemFile(t, m, "/photos/2023/summer/a.jpg", "summer")
+
+	dst := NewURI(LocalScheme, t.TempDir())
+	assert.NoError(t, client.Copy(ctx, memURI("/photos"), dst, true, WithParallel(2)))
+	read := func(p string) string {
+		b, err := os.ReadFile(filepath.Join(dst.Path, p))
+		assert.NoError(t, err)
+		return string(b)
+	}
+	assert.Equal(t, "a", read("photos/a.jpg"))
+	assert.Equal(t, "2023", read("photos/2023/a.jpg"))
+	assert.Equal(t, "summer", read("photos/2023/summer/a.jpg"))
+	info, err := os.Stat(filepath.Join(dst.Path, "photos", "2024"))
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	// A missing destination is the copy itself
+	assert.NoError(t, client.Copy(ctx, memURI("/photos"), dst.Join("backup"), true))
+	assert.Equal(t, "summer", read("backup/2023/summer/a.jpg"))
+
+	// Back to memory, merging into the existing directory
+	assert.NoError(t, client.Copy(ctx, dst.Join("backup"), memURI("/photos/2023"), true))
+	assert.Equal(t, "summer", readMemFile(t, m, "/photos/2023/backup/2023/summer/a.jpg"))
+
+	// A file onto an existing file overwrites it, like cp
+	assert.NoError(t, client.Copy(ctx, memURI("/photos/2023/a.jpg"), dst.Join("photos", "a.jpg"), false))
+	assert.Equal(t, "2023", read("photos/a.jpg"))
+}
+
+// moveCountFS is a MemFS counting its native moves and its reads
+type moveCountFS struct {
+	*MemFS
+	moves, reads *atomic.Int32
+}
+
+func (fs moveCountFS) Move(ctx context.Context, src, dst URI) error {
+	fs.moves.Add(1)
+	return fs.MemFS.Move(ctx, src, dst)
+}
+
+func (fs moveCountFS) Reader(ctx context.Context, uri URI) (io.ReadCloser, error) {
+	fs.reads.Add(1)
+	return fs.MemFS.Reader(ctx, uri)
+}
+
+func TestClientNativeMove(t *testing.T) {
+	m := NewMemFS(0)
+	moves, reads := &atomic.Int32{}, &atomic.Int32{}
+	err := RegisterScheme("move-test", func(Options) FS { return moveCountFS{MemFS: m, moves: moves, reads: reads} })
+	assert.NoError(t, err)
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+	uri := func(p string) URI { return NewURI("move-test", p) }
+	_, err = m.MkDir(ctx, memURI("/src/sub"))
+	assert.NoError(t, err)
+	_, err = m.MkDir(ctx, memURI("/dst/src"))
+	assert.NoError(t, err)
+	writeMemFile(t, m, "/a.txt", "a")
+	writeMemFile(t, m, "/src/b.txt", "b")
+	writeMemFile(t, m, "/src/sub/c.txt", "c")
+	writeMemFile(t, m, "/dst/src/d.txt", "d")
+
+	// A file is moved natively, reported as a single file
+	p := newProgressRecorder()
+	assert.NoError(t, client.Move(ctx, uri("/a.txt"), uri("/dst"), false, WithProgress(p.record)))
+	assert.Equal(t, "a", readMemFile(t, m, "/dst/a.txt"))
+	assert.Equal(t, int32(1), moves.Load())
+	for _, typ := range []ProgressType{ProgressQueued, ProgressStart, ProgressDone} {
+		assert.Len(t, p.events[typ], 1, typ)
+	}
+
+	// A directory merged into an existing one is copied then deleted
+	assert.NoError(t, client.Move(ctx, uri("/src"), uri("/dst"), true))
+	assert.Equal(t, int32(1), moves.Load())
+	assert.Equal(t, "c", readMemFile(t, m, "/dst/src/sub/c.txt"))
+	assert.Equal(t, "d", readMemFile(t, m, "/dst/src/d.txt"))
+	_, err = m.Get(ctx, memURI("/src"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// A new directory is moved natively, without reading any file
+	reads.Store(0)
+	assert.NoError(t, client.Move(ctx, uri("/dst/src"), uri("/moved"), true))
+	assert.Equal(t, int32(2), moves.Load())
+	assert.Equal(t, int32(0), reads.Load())
+	assert.Equal(t, "c", readMemFile(t, m, "/moved/sub/c.txt"))
+
+	// A filtered move is not native
+	assert.NoError(t, client.Move(ctx, uri("/moved"), uri("/filtered"), true, WithFilter(&Filter{Exclude: []string{"*.txt"}})))
+	assert.Equal(t, int32(2), moves.Load())
+	assert.Equal(t, "b", readMemFile(t, m, "/moved/b.txt"))
+
+	// On the local disk, a directory is renamed
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "src", "sub"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "src", "sub", "a.txt"), []byte("a"), 0644))
+	before, err := os.Stat(filepath.Join(dir, "src", "sub", "a.txt"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.Move(ctx, NewURI(LocalScheme, filepath.Join(dir, "src")), NewURI(LocalScheme, filepath.Join(dir, "dst")), true))
+	after, err := os.Stat(filepath.Join(dir, "dst", "sub", "a.txt"))
+	assert.NoError(t, err)
+	assert.True(t, os.SameFile(before, after))
+	assert.Equal(t, []string{"dst"}, dirNames(t, dir))
+}
@@ -0,0 +1,264 @@
+package filesys
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// FileScheme is the explicit scheme of local paths, file:///tmp/a is the same as /tmp/a
+const FileScheme = "file"
+
+var (
+	ErrInvalidURI  = errors.New("invalid URI")
+	ErrNotRelative = errors.New("path is not relative to base")
+)
+
+/*
+URI is a resource identifier, with the form:
+
+	[scheme]://[user@][host][:port][/path][?query]
+
+Local paths have no scheme and are used as is, without escaping nor query.
+Other URIs have a percent-escaped path, object names with spaces, '#' or '?'
+are written as %20, %23 and %3F. The authority (user, host, port) is only parsed
+for schemes registered with WithHostAuthority, for the others, such as gs,
+the whole string after :// is the path, eg. gs://bucket/object has the path bucket/object.
+
+Paths always use forward slashes, whatever the backend.
+*/
+type URI struct {
+	// Scheme of the resource
+	Scheme string
+	// User of the authority, for network backends
+	User string
+	// Host of the authority, for network backends
+	Host string
+	// Port of the authority, for network backends
+	Port string
+	// Path of the resource in the scheme, unescaped
+	Path string
+	// Query holds backend options, such as generation or storage_class
+	Query url.Values
+	// Name of the resource
+	Name string
+}
+
+// String returns the URI in its escaped form, local paths are returned unchanged
+func (u URI) String() string {
+	if u.Scheme == LocalScheme {
+		return u.Path
+	}
+	var b strings.Builder
+	b.WriteString(u.Scheme + "://")
+	if u.Host != "" {
+		if u.User != "" {
+			b.WriteString(url.PathEscape(u.User) + "@")
+		}
+		b.WriteString(u.Host)
+		if u.Port != "" {
+			b.WriteString(":" + u.Port)
+		}
+		if u.Path != "" && !strings.HasPrefix(u.Path, "/") {
+			b.WriteString("/")
+		}
+	}
+	b.WriteString(escapePath(u.Path))
+	if len(u.Query) > 0 {
+		b.WriteString("?" + u.Query.Encode())
+	}
+	return b.String()
+}
+
+// escapePath percent-escapes each segment of a path, keeping the slashes
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// NewURI creates a new URI from a scheme and a path and sets the name to the base of the path
+func NewURI(s string, p string) URI {
+	return URI{Scheme: s, Path: p, Name: path.Base(p)}
+}
+
+// withPath returns a copy of u with a new path and name, keeping the scheme and authority
+func (u URI) withPath(p string) URI {
+	u.Path = p
+	u.Name = path.Base(p)
+	u.Query = nil
+	return u
+}
+
+/*
+Join returns the URI of elem appended to the path of u.
+
+The result is cleaned the same way for every backend, a trailing slash
+is not kept and the query is dropped.
+*/
+func (u URI) Join(elem ...string) URI {
+	return u.withPath(path.Join(append([]string{u.Path}, elem...)...))
+}
+
+// Parent returns the URI of the directory containing u
+func (u URI) Parent() URI {
+	p := u.Path
+	if len(p) > 1 {
+		p = strings.TrimSuffix(p, "/")
+	}
+	return u.withPath(path.Dir(p))
+}
+
+/*
+Rel returns the path of target relative to u, using forward slashes.
+
+returns ErrNotRelative if target is not u or one of its descendants,
+or is on another scheme or host.
+*/
+func (u URI) Rel(target URI) (string, error) {
+	if u.Scheme != target.Scheme || u.Host != target.Host || u.Port != target.Port {
+		return "", ErrNotRelative
+	}
+	base := cleanPath(u.Path)
+	p := cleanPath(target.Path)
+	switch {
+	case p == base:
+		return ".", nil
+	case base == "":
+		return p, nil
+	case base == "/" && strings.HasPrefix(p, "/"):
+		return p[1:], nil
+	case strings.HasPrefix(p, base+"/"):
+		return p[len(base)+1:], nil
+	}
+	return "", ErrNotRelative
+}
+
+// cleanPath cleans a path, "." and "" are both the empty path
+func cleanPath(p string) string {
+	p = path.Clean(p)
+	if p == "." {
+		return ""
+	}
+	return p
+}
+
+/*
+IsDirLike reports whether the URI names a directory by its form:
+an empty path, a path ending with "/" or the "." and ".." elements.
+It does not check the backend.
+*/
+func (u URI) IsDirLike() bool {
+	return u.Path == "" || strings.HasSuffix(u.Path, "/") || u.Name == "." || u.Name == ".."
+}
+
+var re = regexp.MustCompile(`^(?:([a-zA-Z][a-zA-Z0-9+.-]*):\/\/)?(.+)$`)
+
+/*
+ParseURI parses a string into a URI.
+
+Local paths starting with ~ are expanded to the user home directory,
+file:// URIs are parsed as local paths. The tail after the last '?' is the
+query only if it sets known backend options, such as generation or storage_class,
+otherwise the '?' is kept in the path as a glob wildcard.
+
+returns:
+  - ErrInvalidURI if the string is empty or badly escaped, or a file:// URI names another host
+  - a *SchemeError wrapping ErrUnknownScheme if the scheme is not registered
+*/
+func ParseURI(uri string) (URI, error) {
+	// It looks for an optional scheme followed by '://', and then captures the rest of the string
+	matches := re.FindStringSubmatch(uri)
+	if matches == nil || len(matches) != 3 {
+		return NewURI("", uri), ErrInvalidURI
+	}
+	scheme, rest := matches[1], matches[2]
+	if scheme == LocalScheme {
+		return NewURI(LocalScheme, expandHome(rest)), nil
+	}
+	if scheme == FileScheme {
+		// file://localhost/tmp/a is /tmp/a
+		if strings.HasPrefix(rest, "localhost/") {
+			rest = strings.TrimPrefix(rest, "localhost")
+		}
+		if !strings.HasPrefix(rest, "/") && !strings.HasPrefix(rest, "~") {
+			return NewURI(LocalScheme, rest), fmt.Errorf("%w : file URI of the remote host %s", ErrInvalidURI, uri)
+		}
+	} else if !ValidScheme(scheme) {
+		return NewURI(scheme, rest), &SchemeError{Scheme: scheme, Err: ErrUnknownScheme}
+	}
+
+	var u URI
+	if schemeHasAuthority(scheme) {
+		authority := rest
+		if i := strings.IndexAny(rest, "/?"); i >= 0 {
+			authority, rest = rest[:i], rest[i:]
+		} else {
+			rest = ""
+		}
+		if i := strings.LastIndex(authority, "@"); i >= 0 {
+			user, err := url.PathUnescape(authority[:i])
+			if err != nil {
+				return NewURI(scheme, rest), errors.Join(ErrInvalidURI, err)
+			}
+			u.User, authority = user, authority[i+1:]
+		}
+		if i := strings.LastIndex(authority, ":"); i >= 0 && !strings.HasSuffix(authority, "]") {
+			authority, u.Port = authority[:i], authority[i+1:]
+		}
+		u.Host = authority
+	}
+	rawPath, query := splitQuery(rest)
+	p, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return NewURI(scheme, rawPath), errors.Join(ErrInvalidURI, err)
+	}
+	u.Query = query
+	if scheme == FileScheme {
+		scheme, p = LocalScheme, expandHome(p)
+	}
+	u.Scheme, u.Path, u.Name = scheme, p, path.Base(p)
+	return u, nil
+}
+
+// queryKeys are the backend options read from the query of a URI
+var queryKeys = map[string]bool{"generation": true, "storage_class": true}
+
+/*
+splitQuery splits rest at its last '?' if the tail is a query of known keys,
+otherwise the '?' is part of the path, eg. the wildcard of gs://bucket/raw/?.jpg
+*/
+func splitQuery(rest string) (string, url.Values) {
+	i := strings.LastIndex(rest, "?")
+	if i < 0 {
+		return rest, nil
+	}
+	query, err := url.ParseQuery(rest[i+1:])
+	if err != nil || len(query) == 0 {
+		return rest, nil
+	}
+	for key := range query {
+		if !queryKeys[key] {
+			return rest, nil
+		}
+	}
+	return rest[:i], query
+}
+
+// expandHome replaces a leading ~ with the user home directory
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return path.Join(home, p[1:])
+}
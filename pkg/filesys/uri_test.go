@@ -0,0 +1,99 @@
+package filesys
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURIFull(t *testing.T) {
+	err := RegisterScheme("authority-test", func(Options) FS { return NoopFS{} }, WithHostAuthority())
+	assert.NoError(t, err)
+	home, err := os.UserHomeDir()
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		in      string
+		want    URI
+		wantErr error
+	}{
+		{name: "local", in: "dir/file #1?.txt", want: URI{Path: "dir/file #1?.txt", Name: "file #1?.txt"}},
+		{name: "home", in: "~/file.txt", want: URI{Path: path.Join(home, "file.txt"), Name: "file.txt"}},
+		{name: "file scheme", in: "file:///tmp/a%20b.txt", want: URI{Path: "/tmp/a b.txt", Name: "a b.txt"}},
+		{name: "file scheme localhost", in: "file://localhost/tmp/a", want: URI{Path: "/tmp/a", Name: "a"}},
+		{name: "file scheme home", in: "file://~/a.txt", want: URI{Path: path.Join(home, "a.txt"), Name: "a.txt"}},
+		{name: "file scheme other host", in: "file://otherhost/tmp/a", wantErr: ErrInvalidURI},
+		{name: "escaped object", in: "gs://bucket/a%20b%23c%3F.jpg", want: URI{Scheme: GCPBucketScheme, Path: "bucket/a b#c?.jpg", Name: "a b#c?.jpg"}},
+		{
+			name: "query", in: "gs://bucket/obj?generation=12&storage_class=COLDLINE",
+			want: URI{Scheme: GCPBucketScheme, Path: "bucket/obj", Name: "obj", Query: map[string][]string{"generation": {"12"}, "storage_class": {"COLDLINE"}}},
+		},
+		{name: "glob", in: "gs://bucket/raw/?.jpg", want: URI{Scheme: GCPBucketScheme, Path: "bucket/raw/?.jpg", Name: "?.jpg"}},
+		{name: "glob last", in: "mem:///logs/20??", want: URI{Scheme: MemScheme, Path: "/logs/20??", Name: "20??"}},
+		{name: "unknown query", in: "gs://bucket/a?b=1", want: URI{Scheme: GCPBucketScheme, Path: "bucket/a?b=1", Name: "a?b=1"}},
+		{
+			name: "glob and query", in: "gs://bucket/?.jpg?generation=3",
+			want: URI{Scheme: GCPBucketScheme, Path: "bucket/?.jpg", Name: "?.jpg", Query: map[string][]string{"generation": {"3"}}},
+		},
+		{
+			name: "authority", in: "authority-test://me@host:2222/data/x.txt",
+			want: URI{Scheme: "authority-test", User: "me", Host: "host", Port: "2222", Path: "/data/x.txt", Name: "x.txt"},
+		},
+		{name: "authority host only", in: "authority-test://host", want: URI{Scheme: "authority-test", Host: "host", Name: "."}},
+		{name: "bad escape", in: "gs://bucket/100%", wantErr: ErrInvalidURI},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseURI(tc.in)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr == nil {
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestURIString(t *testing.T) {
+	for _, in := range []string{
+		"gs://bucket/a%20b%23c%3F.jpg",
+		"gs://bucket/obj?generation=12",
+		"authority-test://me@host:2222/data/x.txt",
+	} {
+		uri, err := ParseURI(in)
+		assert.NoError(t, err)
+		assert.Equal(t, in, uri.String())
+	}
+	assert.Equal(t, "/tmp/a b.txt", NewURI(LocalScheme, "/tmp/a b.txt").String())
+}
+
+func TestURIHelpers(t *testing.T) {
+	gs := NewURI(GCPBucketScheme, "bucket/dir/")
+	local := NewURI(LocalScheme, "/tmp/dir")
+
+	assert.Equal(t, NewURI(GCPBucketScheme, "bucket/dir/a/b.txt"), gs.Join("a", "b.txt"))
+	assert.Equal(t, NewURI(LocalScheme, "/tmp/dir/a/b.txt"), local.Join("a", "b.txt"))
+	assert.Equal(t, NewURI(LocalScheme, "/tmp/x"), local.Join("../x"))
+
+	assert.Equal(t, NewURI(GCPBucketScheme, "bucket"), gs.Parent())
+	assert.Equal(t, NewURI(LocalScheme, "/tmp"), local.Parent())
+	assert.Equal(t, NewURI(LocalScheme, "/"), NewURI(LocalScheme, "/").Parent())
+	assert.Equal(t, NewURI(LocalScheme, "."), NewURI(LocalScheme, "a.txt").Parent())
+
+	rel, err := gs.Rel(NewURI(GCPBucketScheme, "bucket/dir/a/b.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a/b.txt", rel)
+	rel, err = local.Rel(local)
+	assert.NoError(t, err)
+	assert.Equal(t, ".", rel)
+	_, err = local.Rel(NewURI(LocalScheme, "/tmp/dir2/a"))
+	assert.ErrorIs(t, err, ErrNotRelative)
+	_, err = local.Rel(NewURI(GCPBucketScheme, "/tmp/dir/a"))
+	assert.ErrorIs(t, err, ErrNotRelative)
+
+	assert.True(t, gs.IsDirLike())
+	assert.True(t, NewURI(LocalScheme, ".").IsDirLike())
+	assert.False(t, local.IsDirLike())
+}
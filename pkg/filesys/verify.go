@@ -0,0 +1,138 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+/*
+ChecksumError reports a copied file whose destination checksum differs
+from the checksum of the source stream.
+
+It matches ErrChecksumMismatch with errors.Is.
+*/
+type ChecksumError struct {
+	Algo     HashAlgorithm
+	Expected []byte
+	Actual   []byte
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s : %s source %s, destination %s", ErrChecksumMismatch, e.Algo,
+		hex.EncodeToString(e.Expected), hex.EncodeToString(e.Actual))
+}
+
+func (e *ChecksumError) Unwrap() error { return ErrChecksumMismatch }
+
+/*
+verifyHash returns the algorithm used to verify copies to fs: CRC32C for
+the file systems storing it, so the destination is not read again, and
+SHA256 otherwise.
+*/
+func verifyHash(fs FS) HashAlgorithm {
+	switch fs.(type) {
+	case *GCPBucketFS, *MemFS:
+		return CRC32C
+	}
+	return SHA256
+}
+
+/*
+copyFileVerified copies the file src to dst through stream hashing it with
+algo, then checks it against the checksum of dst, stored or read again.
+
+returns a *PathError wrapping a *ChecksumError if they differ
+*/
+func copyFileVerified(ctx context.Context, src, dst URI, srcFS, dstFS FS, algo HashAlgorithm, stream streamFunc, wopts ...WriterOption) error {
+	h, err := NewHash(algo)
+	if err != nil {
+		return err
+	}
+	srcFile, err := srcFS.Reader(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	// Canceled on failure to drop the partial content, see copyFile
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	dstFile, err := dstFS.Writer(wctx, dst, wopts...)
+	if err != nil {
+		return err
+	}
+	if _, err := CopyContext(ctx, dstFile, io.TeeReader(stream.wrap(ctx, srcFile), h)); err != nil {
+		cancel()
+		dstFile.Close()
+		return err
+	}
+	if err := dstFile.Close(); err != nil {
+		return err
+	}
+	return verifyChecksum(ctx, dstFS, dst, algo, h.Sum(nil))
+}
+
+// verifyChecksum compares expected with the checksum of the file uri
+func verifyChecksum(ctx context.Context, fs FS, uri URI, algo HashAlgorithm, expected []byte) error {
+	actual, err := checksumFS(ctx, fs, uri, algo)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(expected, actual) {
+		return &PathError{Op: "verify", URI: uri, Err: &ChecksumError{Algo: algo, Expected: expected, Actual: actual}}
+	}
+	return nil
+}
+
+// verifyFile compares the checksums of the files src and dst
+func verifyFile(ctx context.Context, src, dst URI, srcFS, dstFS FS, algo HashAlgorithm) error {
+	expected, err := checksumFS(ctx, srcFS, src, algo)
+	if err != nil {
+		return err
+	}
+	return verifyChecksum(ctx, dstFS, dst, algo, expected)
+}
+
+/*
+verifyTree compares every file under src with its copy under dst,
+used after the native copy of a file system.
+
+returns a *TransferError with the files that differ
+*/
+func verifyTree(ctx context.Context, src, dst URI, recursive bool, fs FS, algo HashAlgorithm) error {
+	var errs []error
+	err := fs.Walk(ctx, src, WalkOptions{Recursive: recursive}, func(node Node) error {
+		if node.IsDir {
+			return nil
+		}
+		target := dst
+		if rel, err := src.Rel(node.URI); err == nil && rel != "." {
+			target = dst.Join(rel)
+		}
+		if err := verifyFile(ctx, node.URI, target, fs, fs, algo); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	if len(errs) > 0 {
+		return errors.Join(err, &TransferError{Errs: errs})
+	}
+	return err
+}
+
+// hashWriter hashes the bytes written through it when h is not nil
+func hashWriter(w io.Writer, h hash.Hash) io.Writer {
+	if h == nil {
+		return w
+	}
+	return io.MultiWriter(w, h)
+}
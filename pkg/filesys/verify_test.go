@@ -0,0 +1,95 @@
+package filesys
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// truncatingFS is a MemFS losing the last byte of every file written
+type truncatingFS struct {
+	*MemFS
+}
+
+type truncatingWriter struct {
+	io.WriteCloser
+	last []byte
+}
+
+func (fs truncatingFS) Writer(ctx context.Context, uri URI, opts ...WriterOption) (io.WriteCloser, error) {
+	w, err := fs.MemFS.Writer(ctx, uri, opts...)
+	return &truncatingWriter{WriteCloser: w}, err
+}
+
+// Write holds the last byte back, so it is never written
+func (w *truncatingWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if _, err := w.WriteCloser.Write(append(w.last, p[:len(p)-1]...)); err != nil {
+		return 0, err
+	}
+	w.last = []byte{p[len(p)-1]}
+	return len(p), nil
+}
+
+func TestCopyFileVerified(t *testing.T) {
+	ctx := context.Background()
+	src := NewMemFS(0)
+	writeMemFile(t, src, "/a.txt", "hello")
+	dst := NewURI(LocalScheme, filepath.Join(t.TempDir(), "a.txt"))
+
+	for _, algo := range []HashAlgorithm{MD5, CRC32C, SHA256} {
+		assert.NoError(t, copyFileVerified(ctx, memURI("/a.txt"), dst, src, NewLocalFS(), algo, nil))
+	}
+	b, err := os.ReadFile(dst.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+
+	err = copyFileVerified(ctx, memURI("/a.txt"), memURI("/a.txt"), src, truncatingFS{NewMemFS(0)}, SHA256, nil)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	var checksumErr *ChecksumError
+	assert.ErrorAs(t, err, &checksumErr)
+	assert.Equal(t, SHA256, checksumErr.Algo)
+	assert.NotEqual(t, checksumErr.Expected, checksumErr.Actual)
+
+	err = copyFileVerified(ctx, memURI("/a.txt"), dst, src, NewLocalFS(), "sha1", nil)
+	assert.ErrorIs(t, err, ErrUnknownHash)
+}
+
+func TestMoveVerified(t *testing.T) {
+	err := RegisterScheme("truncating-test", func(Options) FS { return truncatingFS{NewMemFS(0)} })
+	assert.NoError(t, err)
+	ctx := context.Background()
+	client := NewClient()
+	defer client.Close()
+	fs, err := client.FS(ctx, MemScheme)
+	assert.NoError(t, err)
+	m := fs.(*MemFS)
+	_, err = m.MkDir(ctx, memURI("/src/sub"))
+	assert.NoError(t, err)
+	writeMemFile(t, m, "/src/a.txt", "a")
+	writeMemFile(t, m, "/src/sub/b.txt", "b")
+
+	// A truncated copy does not delete the source
+	err = client.Move(ctx, memURI("/src"), NewURI("truncating-test", "/dst"), true, WithVerify(""))
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	var transferErr *TransferError
+	assert.ErrorAs(t, err, &transferErr)
+	assert.Len(t, transferErr.Errs, 2)
+	assert.Equal(t, "b", readMemFile(t, m, "/src/sub/b.txt"))
+
+	// Same file system copies are verified after the native copy
+	dst := NewURI(LocalScheme, t.TempDir())
+	assert.NoError(t, client.Move(ctx, memURI("/src"), dst, true, WithVerify("")))
+	_, err = m.Get(ctx, memURI("/src"))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, client.Copy(ctx, dst.Join("src"), dst.Join("copy"), true, WithVerify(MD5)))
+	b, err := os.ReadFile(filepath.Join(dst.Path, "copy", "sub", "b.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(b))
+}
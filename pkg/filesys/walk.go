@@ -0,0 +1,85 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+)
+
+var (
+	// SkipDir is returned by a WalkFunc to skip the directory it was called with.
+	// When returned for a file, the remaining entries of its directory are skipped.
+	SkipDir = fs.SkipDir
+	// SkipAll is returned by a WalkFunc to stop the walk without an error.
+	SkipAll = fs.SkipAll
+
+	ErrInvalidPageToken = errors.New("invalid page token")
+)
+
+/*
+WalkFunc is called by Walk once per node, as soon as the node is listed.
+
+Returning SkipDir or SkipAll alters the walk, any other error stops it and
+is returned by Walk unchanged.
+*/
+type WalkFunc func(node Node) error
+
+// WalkOptions configures a Walk
+type WalkOptions struct {
+	// Recursive walks sub directories, otherwise only direct children are visited
+	Recursive bool
+	// PageSize hints how many entries to fetch per backend request, 0 uses the backend default
+	PageSize int
+	/*
+		PageToken resumes an interrupted walk right after the node it names.
+		Use the URI path of the last node handled by the previous walk.
+	*/
+	PageToken string
+	/*
+		Prefix only walks the direct children of root whose name starts with it,
+		and their content. Backends listing by prefix push it down to their queries.
+	*/
+	Prefix string
+	// Filter skips the nodes it excludes, it is applied by Client.Walk and Filter.Walk, not by FS implementations
+	Filter *Filter
+}
+
+/*
+walkPosition compares the path components of an entry with the ones
+of a page token, both relative to the walk root.
+
+returns:
+  - -1 if the entry was fully visited before the token
+  - 0 if the entry is the token or one of its ancestors, its children may still have to be visited
+  - 1 if the entry comes after the token
+*/
+func walkPosition(entry, token []string) int {
+	for i := range entry {
+		if i >= len(token) {
+			return 1
+		}
+		if c := strings.Compare(entry[i], token[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// ignoreSkip maps the walk control errors to nil
+func ignoreSkip(err error) error {
+	if errors.Is(err, SkipDir) || errors.Is(err, SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// listWalk collects the nodes of a walk into a slice
+func listWalk(ctx context.Context, fs FS, dir URI, recursive bool) ([]Node, error) {
+	var files []Node
+	err := fs.Walk(ctx, dir, WalkOptions{Recursive: recursive}, func(node Node) error {
+		files = append(files, node)
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,125 @@
+package filesys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newWalkTree creates a tmp tree with files a.txt, b/c.txt, b/d/e.txt, b-f.txt and returns its root
+func newWalkTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "b/c.txt", "b/d/e.txt", "b-f.txt"} {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func walkPaths(t *testing.T, root string, opts WalkOptions, fn WalkFunc) []string {
+	t.Helper()
+	var paths []string
+	err := filesys.Walk(context.Background(), NewURI(LocalScheme, root), opts, func(node Node) error {
+		rel, _ := filepath.Rel(root, node.URI.Path)
+		paths = append(paths, filepath.ToSlash(rel))
+		if fn != nil {
+			return fn(node)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	return paths
+}
+
+func TestWalk(t *testing.T) {
+	root := newWalkTree(t)
+
+	testCases := []struct {
+		name string
+		opts WalkOptions
+		fn   WalkFunc
+		want []string
+	}{
+		{name: "non recursive", opts: WalkOptions{}, want: []string{"a.txt", "b", "b-f.txt"}},
+		{name: "recursive", opts: WalkOptions{Recursive: true}, want: []string{"a.txt", "b", "b/c.txt", "b/d", "b/d/e.txt", "b-f.txt"}},
+		{
+			name: "skip dir",
+			opts: WalkOptions{Recursive: true},
+			fn: func(node Node) error {
+				if node.URI.Name == "d" {
+					return SkipDir
+				}
+				return nil
+			},
+			want: []string{"a.txt", "b", "b/c.txt", "b/d", "b-f.txt"},
+		},
+		{
+			name: "skip all",
+			opts: WalkOptions{Recursive: true},
+			fn: func(node Node) error {
+				if node.URI.Name == "c.txt" {
+					return SkipAll
+				}
+				return nil
+			},
+			want: []string{"a.txt", "b", "b/c.txt"},
+		},
+		{
+			name: "resume after file",
+			opts: WalkOptions{Recursive: true, PageToken: filepath.Join(root, "b", "c.txt")},
+			want: []string{"b/d", "b/d/e.txt", "b-f.txt"},
+		},
+		{
+			name: "resume after dir",
+			opts: WalkOptions{Recursive: true, PageToken: filepath.Join(root, "b")},
+			want: []string{"b/c.txt", "b/d", "b/d/e.txt", "b-f.txt"},
+		},
+		{
+			name: "resume non recursive",
+			opts: WalkOptions{PageToken: filepath.Join(root, "b")},
+			want: []string{"b-f.txt"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, walkPaths(t, root, tc.opts, tc.fn))
+		})
+	}
+}
+
+func TestWalkErrors(t *testing.T) {
+	root := newWalkTree(t)
+	ctx := context.Background()
+
+	err := filesys.Walk(ctx, NewURI(LocalScheme, filepath.Join(root, "missing")), WalkOptions{}, func(Node) error { return nil })
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	err = filesys.Walk(ctx, NewURI(LocalScheme, root), WalkOptions{PageToken: "/elsewhere"}, func(Node) error { return nil })
+	assert.ErrorIs(t, err, ErrInvalidPageToken)
+
+	errStop := os.ErrClosed
+	err = filesys.Walk(ctx, NewURI(LocalScheme, root), WalkOptions{}, func(Node) error { return errStop })
+	assert.ErrorIs(t, err, errStop)
+}
+
+func TestGCPPageToken(t *testing.T) {
+	keys, err := gcpPageToken("bucket", "dir/", "bucket/dir/a/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/", "b.txt"}, keys)
+
+	keys, err = gcpPageToken("bucket", "", "bucket/a/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/"}, keys)
+
+	_, err = gcpPageToken("bucket", "dir/", "other/dir/a")
+	assert.ErrorIs(t, err, ErrInvalidPageToken)
+}
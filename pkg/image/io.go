@@ -1,6 +1,7 @@
 package image
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"image"
@@ -20,6 +21,14 @@ import (
 
 var fileSystem filesys.FS = &filesys.LocalFS{}
 
+// uriFS returns the file system of uri, local paths use fileSystem
+func uriFS(ctx context.Context, uri filesys.URI) (filesys.FS, error) {
+	if uri.Scheme == filesys.LocalScheme {
+		return fileSystem, nil
+	}
+	return filesys.DefaultClient.FS(ctx, uri.Scheme)
+}
+
 type decodeConfig struct {
 	autoOrientation bool
 }
@@ -82,7 +91,12 @@ func Decode(r io.Reader, opts ...DecodeOption) (image.Image, error) {
 //	// Load an image and transform it depending on the EXIF orientation tag (if present).
 //	img, err := imaging.Open("test.jpg", imaging.AutoOrientation(true))
 func Open(uri filesys.URI, opts ...DecodeOption) (image.Image, error) {
-	file, err := fileSystem.Reader(uri)
+	ctx := context.Background()
+	fs, err := uriFS(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	file, err := fs.Reader(ctx, uri)
 	if err != nil {
 		return nil, err
 	}
@@ -245,6 +259,7 @@ func Encode(w io.Writer, img image.Image, format Format, opts ...EncodeOption) e
 // Save saves the image to file with the specified filename.
 // The format is determined from the filename extension:
 // "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff") and "bmp" are supported.
+// The filename can be the URI of any registered file system, such as "mem:///out.png".
 //
 // Examples:
 //
@@ -258,7 +273,16 @@ func Save(img image.Image, filename string, opts ...EncodeOption) (err error) {
 	if err != nil {
 		return err
 	}
-	file, err := fileSystem.Writer(filesys.NewURI("", filename))
+	ctx := context.Background()
+	uri, err := filesys.ParseURI(filename)
+	if err != nil {
+		return err
+	}
+	fs, err := uriFS(ctx, uri)
+	if err != nil {
+		return err
+	}
+	file, err := fs.Writer(ctx, uri)
 	if err != nil {
 		return err
 	}
@@ -145,6 +145,26 @@ func TestOpenSave(t *testing.T) {
 	}
 }
 
+func TestOpenSaveMem(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Pix[0] = 0xff
+
+	if err := Save(img, "mem:///test_open_save.png"); err != nil {
+		t.Fatalf("failed to save image: %v", err)
+	}
+	uri, err := filesys.ParseURI("mem:///test_open_save.png")
+	if err != nil {
+		t.Fatalf("failed to parse uri: %v", err)
+	}
+	img2, err := Open(uri)
+	if err != nil {
+		t.Fatalf("failed to open image: %v", err)
+	}
+	if !compareNRGBA(Clone(img2), img, 0) {
+		t.Fatalf("bad encode-decode result: got %#v want %#v", img2, img)
+	}
+}
+
 func TestFormats(t *testing.T) {
 	formatNames := map[Format]string{
 		JPEG:       "JPEG",